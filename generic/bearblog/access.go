@@ -0,0 +1,255 @@
+package bearblog
+
+import (
+	"crypto/hmac"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/pkg/passhash"
+)
+
+// previewLifetime is how long a shared preview link works.
+const previewLifetime = 7 * 24 * time.Hour
+
+// sessionUnlockPrefix is the start of the session key that remembers a
+// password protected post was unlocked. The post ID is added to the end.
+const sessionUnlockPrefix = "bearblog_unlock_"
+
+// postAccess controls who can find and read a published post.
+type postAccess struct {
+	// Unlisted posts can be read by anyone with the URL but are left out of
+	// the blog, tag pages, search, and feeds.
+	Unlisted bool `json:"unlisted"`
+	// PasswordHash is set if readers must enter a password to read the post.
+	PasswordHash string `json:"password"`
+	// Published is true if a hidden post is published. Hidden posts are
+	// saved as drafts in the site storage so other plugins, like the feeds
+	// and the sitemap, never list them.
+	Published bool `json:"published,omitempty"`
+}
+
+// protected returns true if the post requires a password.
+func (a postAccess) protected() bool {
+	return len(a.PasswordHash) > 0
+}
+
+// hidden returns true if the post is unlisted or requires a password.
+func (a postAccess) hidden() bool {
+	return a.Unlisted || a.protected()
+}
+
+// restorePublished sets the published flag of a hidden post from the access
+// settings since the post is saved as a draft.
+func restorePublished(post ambient.Post, access postAccess) ambient.Post {
+	if access.hidden() && access.Published {
+		post.Published = true
+	}
+
+	return post
+}
+
+// postAccesses returns the access settings of posts mapped to the post ID.
+// Posts that are listed and don't have a password are not included.
+func (p *Plugin) postAccesses() (map[string]postAccess, error) {
+	all := make(map[string]postAccess)
+	err := p.loadData(dataAccess, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// postAccess returns the access settings of a post.
+func (p *Plugin) postAccess(ID string) (postAccess, error) {
+	all, err := p.postAccesses()
+	if err != nil {
+		return postAccess{}, err
+	}
+
+	return all[ID], nil
+}
+
+// setPostAccess sets the access settings of a post and refreshes the data
+// derived from posts. The post is saved again if it becomes hidden or visible
+// so it's only published in the site storage when it's visible.
+func (p *Plugin) setPostAccess(ID string, access postAccess) error {
+	stored, err := p.Site.PostByID(ID)
+	if err != nil {
+		// Purged posts don't exist anymore so only the settings are removed.
+		err = p.writePostAccess(ID, access, false)
+		if err != nil {
+			return err
+		}
+
+		p.postsChanged()
+		return nil
+	}
+
+	post, err := p.postByID(ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.writePostAccess(ID, access, post.Published)
+	if err != nil {
+		return err
+	}
+
+	if stored.Published != (post.Published && !access.hidden()) {
+		return p.savePost(ID, post)
+	}
+
+	p.postsChanged()
+	return nil
+}
+
+// writePostAccess saves the access settings of a post that is published or
+// not. Settings that are the same as a regular post are removed. The post
+// must be saved after if it becomes hidden or visible.
+func (p *Plugin) writePostAccess(ID string, access postAccess, published bool) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]postAccess)
+	err := p.loadData(dataAccess, &all)
+	if err != nil {
+		return err
+	}
+
+	access.Published = access.hidden() && published
+	if access == (postAccess{}) {
+		delete(all, ID)
+	} else {
+		all[ID] = access
+	}
+
+	return p.saveData(dataAccess, all)
+}
+
+// hidePost returns the post to save to the site storage. Hidden posts are
+// saved as drafts and whether they are published is kept in the access
+// settings.
+func (p *Plugin) hidePost(ID string, post ambient.Post) (ambient.Post, error) {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]postAccess)
+	err := p.loadData(dataAccess, &all)
+	if err != nil {
+		return post, err
+	}
+
+	access, ok := all[ID]
+	if !ok || !access.hidden() {
+		return post, nil
+	}
+
+	if access.Published != post.Published {
+		access.Published = post.Published
+		all[ID] = access
+		err = p.saveData(dataAccess, all)
+		if err != nil {
+			return post, err
+		}
+	}
+
+	post.Published = false
+	return post, nil
+}
+
+// accessFormValues returns the access settings from the post form. The
+// current password is kept unless a new one is entered or it's removed.
+func accessFormValues(r *http.Request, current postAccess) (postAccess, error) {
+	access := postAccess{
+		Unlisted:     r.FormValue("unlisted") == "on",
+		PasswordHash: current.PasswordHash,
+	}
+
+	if r.FormValue("remove_password") == "on" {
+		access.PasswordHash = ""
+	} else if password := r.FormValue("post_password"); len(password) > 0 {
+		hash, err := passhash.HashString(password)
+		if err != nil {
+			return access, err
+		}
+		access.PasswordHash = hash
+	}
+
+	return access, nil
+}
+
+// unlocked returns true if the reader can see a password protected post
+// because they are logged in or entered the password earlier in the session.
+func (p *Plugin) unlocked(r *http.Request, ID string, access postAccess) (bool, error) {
+	if !access.protected() {
+		return true, nil
+	}
+
+	if _, err := p.Site.AuthenticatedUser(r); err == nil {
+		return true, nil
+	}
+
+	// The signature includes the password hash so changing the password
+	// locks the post again.
+	return p.validSignature(p.Site.SessionValue(r, sessionUnlockPrefix+ID), "unlock", ID, access.PasswordHash)
+}
+
+// unlock checks the password of a protected post and remembers it in the
+// session. False is returned if the password is not correct.
+func (p *Plugin) unlock(r *http.Request, ID string, access postAccess, password string) (bool, error) {
+	if !passhash.MatchString(access.PasswordHash, password) {
+		return false, nil
+	}
+
+	sig, err := p.sign("unlock", ID, access.PasswordHash)
+	if err != nil {
+		return false, err
+	}
+
+	return true, p.Site.SetSessionValue(r, sessionUnlockPrefix+ID, sig)
+}
+
+// previewToken returns a token that lets anyone with the link preview the
+// post until the expiration time.
+func previewToken(key []byte, ID string, expires time.Time) string {
+	ts := strconv.FormatInt(expires.Unix(), 10)
+	return ts + "." + signWithKey(key, "preview", ID, ts)
+}
+
+// validPreviewToken returns true if the preview token was created for the
+// post and hasn't expired.
+func validPreviewToken(key []byte, ID string, token string, now time.Time) bool {
+	arr := strings.SplitN(token, ".", 2)
+	if len(arr) != 2 || !hmac.Equal([]byte(arr[1]), []byte(signWithKey(key, "preview", ID, arr[0]))) {
+		return false
+	}
+
+	ts, err := strconv.ParseInt(arr[0], 10, 64)
+	return err == nil && now.Before(time.Unix(ts, 0))
+}
+
+// canPreview returns true if the request can see a post that isn't
+// published yet. Logged in users can preview with ?preview=true and everyone
+// else needs a preview token.
+func (p *Plugin) canPreview(r *http.Request, ID string) (bool, error) {
+	preview := r.URL.Query().Get("preview")
+	if len(preview) == 0 {
+		return false, nil
+	}
+
+	if strings.ToLower(preview) == "true" {
+		_, err := p.Site.AuthenticatedUser(r)
+		return err == nil, nil
+	}
+
+	key, err := p.secretKey()
+	if err != nil {
+		return false, err
+	}
+
+	return validPreviewToken(key, ID, preview, time.Now()), nil
+}
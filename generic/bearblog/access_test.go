@@ -0,0 +1,67 @@
+package bearblog
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambientkit/plugin/pkg/passhash"
+)
+
+func TestPreviewToken(t *testing.T) {
+	key := []byte("secret")
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := previewToken(key, "post1", now.Add(previewLifetime))
+
+	tests := []struct {
+		name     string
+		key      []byte
+		postID   string
+		token    string
+		now      time.Time
+		expected bool
+	}{
+		{"valid", key, "post1", token, now.Add(time.Hour), true},
+		{"expired", key, "post1", token, now.Add(previewLifetime), false},
+		{"other post", key, "post2", token, now, false},
+		{"other key", []byte("other"), "post1", token, now, false},
+		{"malformed", key, "post1", "true", now, false},
+		{"changed expiry", key, "post1", "9999999999" + token[strings.Index(token, "."):], now, false},
+	}
+
+	for _, tt := range tests {
+		if got := validPreviewToken(tt.key, tt.postID, tt.token, tt.now); got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestAccessFormValues(t *testing.T) {
+	hash, err := passhash.HashString("current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := postAccess{PasswordHash: hash}
+
+	form := func(v url.Values) postAccess {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(v.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		a, err := accessFormValues(r, current)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	if a := form(url.Values{"unlisted": {"on"}}); !a.Unlisted || a.PasswordHash != hash {
+		t.Errorf("expected unlisted with the current password, got %+v", a)
+	}
+	if a := form(url.Values{"post_password": {"new"}}); a.Unlisted || !passhash.MatchString(a.PasswordHash, "new") {
+		t.Errorf("expected the new password, got %+v", a)
+	}
+	if a := form(url.Values{"post_password": {"new"}, "remove_password": {"on"}}); a.protected() {
+		t.Errorf("expected the password to be removed, got %+v", a)
+	}
+}
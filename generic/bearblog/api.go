@@ -0,0 +1,81 @@
+package bearblog
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/plugin/middleware/jwt"
+	"github.com/ambientkit/plugin/pkg/jwtoken"
+)
+
+// apiTokenLifetime is how long a token from the API works before a new one
+// is needed.
+const apiTokenLifetime = 7 * 24 * time.Hour
+
+// RequireToken requires a bearer token from /api/v1/token on the API routes.
+// Tokens are signed with the secret key of the plugin. Only the /api/v1/
+// routes of this plugin are checked so other plugins can have API routes.
+func (p *Plugin) RequireToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, p.Path("/api/v1/")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		webtoken, err := p.apiWebtoken()
+		if err != nil {
+			p.Log.Error("bearblog: error getting API secret: %v", err.Error())
+			apiError(w, http.StatusInternalServerError, "")
+			return
+		}
+
+		whitelist := []string{"POST " + p.Path("/api/v1/token")}
+		jwt.NewJWT(whitelist, webtoken, p.Site).Handler(h).ServeHTTP(w, r)
+	})
+}
+
+// apiWebtoken returns the configuration to generate and verify API tokens.
+func (p *Plugin) apiWebtoken() (*jwtoken.Configuration, error) {
+	key, err := p.secretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return jwtoken.New(key, apiTokenLifetime), nil
+}
+
+// apiUser returns the user of an API request. Tokens can outlive the user
+// they were issued to so the user must still be the site owner or a stored
+// user.
+func (p *Plugin) apiUser(r *http.Request) (currentUser, bool, error) {
+	cu, err := p.currentUser(r)
+	if err != nil {
+		return cu, false, nil
+	}
+
+	owner, err := p.ownerUsername()
+	if err != nil {
+		return cu, false, err
+	} else if cu.Username == owner {
+		return cu, true, nil
+	}
+
+	_, found, err := p.user(cu.Username)
+	return cu, found, err
+}
+
+// apiError sends an error in the same format as the JWT middleware.
+func apiError(w http.ResponseWriter, status int, message string) error {
+	resp := new(jwt.GenericResponse)
+	resp.Body.Status = http.StatusText(status)
+	resp.Body.Message = message
+	return writeJSON(w, status, resp.Body)
+}
+
+// apiServerError logs an error and sends a generic error so the details are
+// not shown to the client.
+func (p *Plugin) apiServerError(w http.ResponseWriter, err error) error {
+	p.Log.Error("bearblog: api error: %v", err.Error())
+	return apiError(w, http.StatusInternalServerError, "")
+}
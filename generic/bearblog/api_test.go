@@ -0,0 +1,69 @@
+package bearblog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestAPIError(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := apiError(w, http.StatusNotFound, "post not found"); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %v, got %v", http.StatusNotFound, w.Code)
+	}
+
+	expected := `{"status":"Not Found","message":"post not found"}`
+	if got := strings.TrimSpace(w.Body.String()); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestDecodeAPIBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+	}{
+		{"valid", `{"title":"a"}`, true},
+		{"unknown field", `{"nope":"a"}`, false},
+		{"malformed", `{"title":`, false},
+		{"too large", `{"title":"` + strings.Repeat("a", maxAPIBody) + `"}`, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(tt.body))
+		var post ambient.Post
+		err := decodeAPIBody(httptest.NewRecorder(), r, &post)
+		if got := err == nil; got != tt.valid {
+			t.Errorf("%v: expected valid %v, got %v (%v)", tt.name, tt.valid, got, err)
+		}
+	}
+}
+
+func TestPrepareAPIPost(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	post := ambient.Post{
+		Tags: ambient.TagList{{Name: " go "}, {Name: ""}, {Name: "go"}, {Name: "web"}},
+	}
+	prepareAPIPost(&post, now)
+
+	if !post.Timestamp.Equal(now) {
+		t.Errorf("expected timestamp %v, got %v", now, post.Timestamp)
+	}
+
+	if len(post.Tags) != 2 || post.Tags[0].Name != "go" || post.Tags[1].Name != "web" {
+		t.Fatalf("expected tags go and web, got %v", post.Tags)
+	}
+
+	if !post.Tags[0].Timestamp.Equal(now) {
+		t.Errorf("expected tag timestamp %v, got %v", now, post.Tags[0].Timestamp)
+	}
+}
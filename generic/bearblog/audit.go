@@ -0,0 +1,80 @@
+package bearblog
+
+import (
+	"net/http"
+	"time"
+)
+
+// maxAuditEvents is the number of audit events that are kept. The oldest
+// events are removed first.
+const maxAuditEvents = 500
+
+// Events in the audit log.
+const (
+	auditLoginSuccess = "login_success"
+	auditLoginFailure = "login_failure"
+	auditMFAFailure   = "mfa_failure"
+	auditLoginLocked  = "login_locked"
+	auditLogout       = "logout"
+	auditUnlock       = "unlock"
+	auditAPIToken     = "api_token"
+)
+
+// auditEvent is an entry in the audit log.
+type auditEvent struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"useragent"`
+	Detail    string    `json:"detail"`
+}
+
+// auditEvents returns the audit log with the newest events first.
+func (p *Plugin) auditEvents() ([]auditEvent, error) {
+	arr := make([]auditEvent, 0)
+	err := p.loadData(dataAudit, &arr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Events are stored oldest first.
+	for i, j := 0, len(arr)-1; i < j; i, j = i+1, j-1 {
+		arr[i], arr[j] = arr[j], arr[i]
+	}
+
+	return arr, nil
+}
+
+// audit adds an event for the request to the audit log. Errors are logged
+// instead of returned so a failure to write the log doesn't stop a login.
+func (p *Plugin) audit(r *http.Request, event string, username string, detail string) {
+	e := auditEvent{
+		Time:      time.Now(),
+		Event:     event,
+		Username:  username,
+		IP:        p.clientIP(r),
+		UserAgent: r.UserAgent(),
+		Detail:    detail,
+	}
+
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	arr := make([]auditEvent, 0)
+	err := p.loadData(dataAudit, &arr)
+	if err != nil {
+		p.Log.Error("bearblog: could not load audit log: %v", err.Error())
+		return
+	}
+
+	arr = append(arr, e)
+	if len(arr) > maxAuditEvents {
+		arr = arr[len(arr)-maxAuditEvents:]
+	}
+
+	err = p.saveData(dataAudit, arr)
+	if err != nil {
+		p.Log.Error("bearblog: could not save audit log: %v", err.Error())
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"embed"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/pkg/blobstore"
 )
 
-//go:embed template/partial/*.tmpl template/content/*.tmpl
+//go:embed template/partial/*.tmpl template/content/*.tmpl js/*.js
 var assets embed.FS
 
 // Plugin represents an Ambient plugin.
@@ -18,6 +21,21 @@ type Plugin struct {
 	*ambient.PluginBase
 
 	passwordHash string
+	searchIndex  *searchIndex
+	relatedIndex *relatedIndex
+	blobStore    blobstore.Store
+	loginLimiter *loginLimiter
+	// commentLimiter limits the comments from each IP address.
+	commentLimiter *rateLimiter
+	// exportHandler serves the pages of static exports.
+	exportHandler http.Handler
+
+	// dataMu protects the data stored in plugin settings from concurrent
+	// updates.
+	dataMu sync.Mutex
+	// cache has the data stored in plugin settings so changes made during a
+	// request can be saved together.
+	cache *dataCache
 }
 
 // New returns an Ambient plugin that provides basic blog functionality.
@@ -25,10 +43,22 @@ func New(passwordHash string) *Plugin {
 	return &Plugin{
 		PluginBase: &ambient.PluginBase{},
 
-		passwordHash: passwordHash,
+		passwordHash:   passwordHash,
+		searchIndex:    newSearchIndex(),
+		relatedIndex:   newRelatedIndex(),
+		blobStore:      blobstore.NewLocalStore(defaultUploadFolder),
+		loginLimiter:   newLoginLimiter(),
+		commentLimiter: newRateLimiter(maxReaderComments, commentWindow),
+		cache:          newDataCache(),
 	}
 }
 
+// SetUploadStore sets where uploaded images are stored. Images are stored on
+// the local disk by default.
+func (p *Plugin) SetUploadStore(store blobstore.Store) {
+	p.blobStore = store
+}
+
 // PluginName returns the plugin name.
 func (p *Plugin) PluginName() string {
 	return "bearblog"
@@ -49,6 +79,7 @@ func (p *Plugin) GrantRequests() []ambient.GrantRequest {
 		{Grant: ambient.GrantPluginSettingWrite, Description: "Write own plugin settings."},
 		{Grant: ambient.GrantSitePostRead, Description: "Read all site posts."},
 		{Grant: ambient.GrantSitePostWrite, Description: "Create and edit site posts."},
+		{Grant: ambient.GrantSitePostDelete, Description: "Delete site posts that are moved to the trash."},
 		{Grant: ambient.GrantSiteSchemeRead, Description: "Read site scheme."},
 		{Grant: ambient.GrantSiteSchemeWrite, Description: "Update the site scheme."},
 		{Grant: ambient.GrantSiteURLRead, Description: "Read the site URL."},
@@ -60,7 +91,7 @@ func (p *Plugin) GrantRequests() []ambient.GrantRequest {
 		{Grant: ambient.GrantSiteAssetWrite, Description: "Access to write blog meta tags to the header and add a nav and footer."},
 		{Grant: ambient.GrantSiteFuncMapWrite, Description: "Access to create global FuncMaps for templates."},
 		{Grant: ambient.GrantRouterRouteWrite, Description: "Access to create routes for editing the blog posts."},
-		{Grant: ambient.GrantRouterMiddlewareWrite, Description: "Access to create global middleware to protect /dashboard/* routes from anonymous users."},
+		{Grant: ambient.GrantRouterMiddlewareWrite, Description: "Access to create global middleware to protect /dashboard/* routes from anonymous users, /dashboard/plugins from users that aren't admins, and /api/v1/* from requests without a token."},
 	}
 }
 
@@ -77,6 +108,23 @@ const (
 	Footer = "Footer"
 	// AllowHTMLinMarkdown allows user to set if they allow HTML in markdown.
 	AllowHTMLinMarkdown = "Allow HTML in Markdown"
+	// PageSize allows user to set the number of posts on each page of the blog.
+	PageSize = "Posts Per Page"
+	// Comments allows user to set if readers can comment on posts.
+	Comments = "Comments"
+	// TrashRetention allows user to set how many days deleted posts are kept.
+	TrashRetention = "Trash Retention Days"
+	// Language allows user to set the language of posts that don't set one.
+	Language = "Language"
+	// MicropubTokens allows user to set the tokens Micropub clients use to
+	// publish posts.
+	MicropubTokens = "Micropub Tokens"
+	// SocialImage allows user to set the image shown when posts without their
+	// own image are shared.
+	SocialImage = "Social Image"
+	// TrustedProxies allows user to set the reverse proxies that can set the
+	// client IP address with the X-Forwarded-For header.
+	TrustedProxies = "Trusted Proxies"
 
 	// Username allows user to set the login username.
 	Username = "Username"
@@ -132,13 +180,61 @@ func (p *Plugin) Settings() []ambient.Setting {
 			Name: AllowHTMLinMarkdown,
 			Type: ambient.Checkbox,
 		},
+		{
+			Name:    PageSize,
+			Default: strconv.Itoa(defaultPageSize),
+		},
+		{
+			Name: Comments,
+			Type: ambient.Checkbox,
+			Description: ambient.SettingDescription{
+				Text: "Allow readers to comment on posts. Comments are shown after they are approved.",
+				URL:  "/dashboard/comments",
+			},
+		},
+		{
+			Name:    TrashRetention,
+			Default: strconv.Itoa(defaultTrashRetention),
+			Description: ambient.SettingDescription{
+				Text: "Days to keep deleted posts in the trash before they are permanently removed. Set to 0 to keep them until they are purged.",
+				URL:  "/dashboard/trash",
+			},
+		},
+		{
+			Name:    Language,
+			Default: defaultLanguage,
+			Description: ambient.SettingDescription{
+				Text: "Language code of posts that don't set their own language, like 'en' or 'de'.",
+			},
+		},
+		{
+			Name: MicropubTokens,
+			Type: ambient.InputPassword,
+			Description: ambient.SettingDescription{
+				Text: "Tokens that Micropub clients can use to publish posts to /micropub, separated by commas. Leave empty to turn off Micropub.",
+			},
+		},
+		{
+			Name: SocialImage,
+			Description: ambient.SettingDescription{
+				Text: "URL of the image shown when posts without their own social image are shared on social media and in chat apps.",
+			},
+		},
+		{
+			Name: TrustedProxies,
+			Description: ambient.SettingDescription{
+				Text: "IP addresses or CIDR ranges of the reverse proxies in front of the site, separated by commas. The X-Forwarded-For header from these proxies is used to find the IP address of visitors for login lockouts and the audit log. Leave empty if the site isn't behind a proxy.",
+			},
+		},
 	}
 }
 
 // Routes sets routes for the plugin.
 func (p *Plugin) Routes() {
 	p.Mux.Get("/blog", p.postIndex)
+	p.Mux.Get("/blog/{section}/{name}", p.postArchiveIndex)
 	p.Mux.Get("/{slug}", p.postShow)
+	p.Mux.Post("/{slug}", p.postSubmit)
 
 	p.Mux.Get("/login/{slug}", p.login)
 	p.Mux.Post("/login/{slug}", p.loginPost)
@@ -153,11 +249,54 @@ func (p *Plugin) Routes() {
 	p.Mux.Post("/dashboard/mfa", p.mfaPost)
 
 	p.Mux.Get("/dashboard/posts", p.postAdminIndex)
+	p.Mux.Post("/dashboard/posts", p.postAdminBulk)
 	p.Mux.Get("/dashboard/posts/new", p.postAdminCreate)
 	p.Mux.Post("/dashboard/posts/new", p.postAdminStore)
 	p.Mux.Get("/dashboard/posts/{id}", p.postAdminEdit)
 	p.Mux.Post("/dashboard/posts/{id}", p.postAdminUpdate)
-	p.Mux.Get("/dashboard/posts/{id}/delete", p.postAdminDestroy)
+	p.Mux.Post("/dashboard/posts/{id}/delete", p.postAdminDestroy)
+	p.Mux.Get("/dashboard/posts/{id}/revisions", p.postAdminRevisions)
+	p.Mux.Post("/dashboard/posts/{id}/revisions", p.postAdminRevisionRestore)
+	p.Mux.Post("/dashboard/posts/{id}/draft", p.postAdminDraft)
+	p.Mux.Post("/dashboard/preview", p.postAdminPreview)
+
+	p.Mux.Get("/dashboard/trash", p.trashIndex)
+	p.Mux.Post("/dashboard/trash", p.trashUpdate)
+
+	p.Mux.Get("/dashboard/export", p.exportPosts)
+	p.Mux.Get("/dashboard/export/static", p.exportStatic)
+	p.Mux.Get("/dashboard/import", p.importPosts)
+	p.Mux.Post("/dashboard/import", p.importPostsPost)
+
+	p.Mux.Get("/dashboard/uploads", p.uploadIndex)
+	p.Mux.Post("/dashboard/uploads", p.uploadStore)
+	p.Mux.Get("/uploads/{name}", p.uploadShow)
+
+	p.Mux.Get("/dashboard/users", p.userIndex)
+	p.Mux.Post("/dashboard/users", p.userStore)
+	p.Mux.Get("/dashboard/users/{username}", p.userEdit)
+	p.Mux.Post("/dashboard/users/{username}", p.userUpdate)
+
+	p.Mux.Get("/dashboard/comments", p.commentIndex)
+	p.Mux.Post("/dashboard/comments", p.commentModerate)
+
+	p.Mux.Get("/dashboard/security", p.securityIndex)
+	p.Mux.Post("/dashboard/security", p.securityUnlock)
+	p.Mux.Get("/dashboard/menu", p.menuEdit)
+	p.Mux.Post("/dashboard/menu", p.menuUpdate)
+
+	p.Mux.Get("/micropub", p.micropubQuery)
+	p.Mux.Post("/micropub", p.micropubPost)
+
+	p.Mux.Post("/api/v1/token", p.apiToken)
+	p.Mux.Get("/api/v1/posts", p.apiPostIndex)
+	p.Mux.Post("/api/v1/posts", p.apiPostStore)
+	p.Mux.Get("/api/v1/posts/{id}", p.apiPostShow)
+	p.Mux.Put("/api/v1/posts/{id}", p.apiPostUpdate)
+	p.Mux.Delete("/api/v1/posts/{id}", p.apiPostDestroy)
+	p.Mux.Get("/api/v1/tags", p.apiTagIndex)
+	p.Mux.Get("/api/v1/site", p.apiSiteShow)
+	p.Mux.Put("/api/v1/site", p.apiSiteUpdate)
 }
 
 // Assets returns a list of assets and an embedded filesystem.
@@ -212,6 +351,56 @@ func (p *Plugin) Assets() ([]ambient.Asset, ambient.FileSystemReader) {
 		},
 	})
 
+	arr = append(arr, ambient.Asset{
+		Filetype: ambient.AssetGeneric,
+		Location: ambient.LocationHead,
+		Inline:   true,
+		Content:  `{{if .prevurl}}<link rel="prev" href="{{.prevurl}}">{{end}}{{if .nexturl}}<link rel="next" href="{{.nexturl}}">{{end}}`,
+	})
+
+	// Show a preview when posts are shared.
+	arr = append(arr, ambient.Asset{
+		Filetype: ambient.AssetGeneric,
+		Location: ambient.LocationHead,
+		Inline:   true,
+		Content: `{{with .og}}<meta property="og:title" content="{{.title}}">` +
+			`<meta property="og:description" content="{{.description}}">` +
+			`<meta property="og:url" content="{{.url}}">` +
+			`<meta property="og:type" content="{{.type}}">` +
+			`{{if .sitename}}<meta property="og:site_name" content="{{.sitename}}">{{end}}` +
+			`{{if .image}}<meta property="og:image" content="{{.image}}">{{end}}` +
+			`<meta name="twitter:card" content="{{.card}}">{{end}}` +
+			`{{with .jsonld}}<script type="application/ld+json">{{.}}</script>{{end}}`,
+	})
+
+	arr = append(arr, ambient.Asset{
+		Filetype: ambient.AssetGeneric,
+		Location: ambient.LocationHead,
+		Inline:   true,
+		Content:  `{{range .hreflangs}}<link rel="alternate" hreflang="{{.lang}}" href="{{.url}}">{{end}}`,
+	})
+
+	// Let Micropub clients find the endpoint.
+	micropubTokens, err := p.micropubTokens()
+	if err == nil && len(micropubTokens) > 0 {
+		arr = append(arr, ambient.Asset{
+			Filetype:   ambient.AssetGeneric,
+			Location:   ambient.LocationHead,
+			TagName:    "link",
+			ClosingTag: false,
+			Attributes: []ambient.Attribute{
+				{
+					Name:  "rel",
+					Value: "micropub",
+				},
+				{
+					Name:  "href",
+					Value: p.Path("/micropub"),
+				},
+			},
+		})
+	}
+
 	siteAuthor, err := p.Site.PluginSettingString(Author)
 	if err == nil && len(siteAuthor) > 0 {
 		arr = append(arr, ambient.Asset{
@@ -246,16 +435,45 @@ func (p *Plugin) Assets() ([]ambient.Asset, ambient.FileSystemReader) {
 		Inline:   true,
 	})
 
+	arr = append(arr, ambient.Asset{
+		Path:     "js/upload.js",
+		Filetype: ambient.AssetJavaScript,
+		Location: ambient.LocationBody,
+		Auth:     ambient.AuthOnly,
+	})
+
+	arr = append(arr, ambient.Asset{
+		Path:     "js/editor.js",
+		Filetype: ambient.AssetJavaScript,
+		Location: ambient.LocationBody,
+		Auth:     ambient.AuthOnly,
+	})
+
 	return arr, &assets
 }
 
 // Middleware returns router middleware.
 func (p *Plugin) Middleware() []func(next http.Handler) http.Handler {
 	return []func(next http.Handler) http.Handler{
+		p.SaveData,
 		p.DisallowAnon,
+		p.RequireToken,
 	}
 }
 
+// SaveData writes the data changed during a request to storage once the
+// request is done so a request only saves the storage once.
+func (p *Plugin) SaveData(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+
+		err := p.flushData()
+		if err != nil {
+			p.Log.Error("bearblog: could not save data: %v", err.Error())
+		}
+	})
+}
+
 // DisallowAnon does not allow anonymous users to access the page.
 func (p *Plugin) DisallowAnon(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -267,6 +485,20 @@ func (p *Plugin) DisallowAnon(h http.Handler) http.Handler {
 				p.Redirect(w, r, "/", http.StatusFound)
 				return
 			}
+
+			// Users that were deleted are logged out.
+			cu, err := p.currentUser(r)
+			if err != nil {
+				p.endUnknownSession(r, err)
+				p.Redirect(w, r, "/", http.StatusFound)
+				return
+			}
+
+			// Only admins can manage plugins.
+			if strings.HasPrefix(r.URL.Path, p.Path("/dashboard/plugins")) && cu.Role != roleAdmin {
+				p.Redirect(w, r, "/dashboard/posts", http.StatusFound)
+				return
+			}
 		}
 
 		h.ServeHTTP(w, r)
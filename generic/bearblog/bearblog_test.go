@@ -9,6 +9,8 @@ import (
 	"github.com/ambientkit/ambient"
 	"github.com/ambientkit/ambient/pkg/ambientapp"
 	"github.com/ambientkit/plugin/generic/bearblog"
+	"github.com/ambientkit/plugin/generic/rssfeed"
+	"github.com/ambientkit/plugin/generic/sitemap"
 	"github.com/ambientkit/plugin/logger/zaplogger"
 	"github.com/ambientkit/plugin/pkg/docgen"
 	"github.com/ambientkit/plugin/pkg/passhash"
@@ -22,6 +24,19 @@ func ExampleNew() {
 		log.Fatalln(err.Error())
 	}
 
+	blog := bearblog.New(base64.StdEncoding.EncodeToString([]byte(s)))
+
+	// List the archive and series pages in the sitemap and label the posts in
+	// each series in the RSS feed. Translated posts are linked to each other
+	// in the sitemap and posts show their author in the feed. Unlisted and password
+	// protected posts are saved as drafts so both leave them out.
+	sm := sitemap.New()
+	sm.AddPageSource(blog.SitemapPages)
+	sm.AddAlternateSource(blog.SitemapAlternates)
+	feed := rssfeed.New()
+	feed.AddCategorySource(blog.FeedCategories)
+	feed.AddAuthorSource(blog.FeedAuthors)
+
 	plugins := &ambient.PluginLoader{
 		// Core plugins are implicitly trusted.
 		Router:         nil,
@@ -31,7 +46,9 @@ func ExampleNew() {
 		// will be enabled and given full access.
 		TrustedPlugins: map[string]bool{},
 		Plugins: []ambient.Plugin{
-			bearblog.New(base64.StdEncoding.EncodeToString([]byte(s))),
+			blog,
+			sm,
+			feed,
 		},
 		Middleware: []ambient.MiddlewarePlugin{
 			// Middleware - executes top to bottom.
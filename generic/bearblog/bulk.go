@@ -0,0 +1,198 @@
+package bearblog
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+// Actions that can be applied to many posts at once from the post list.
+const (
+	bulkPublish   = "publish"
+	bulkUnpublish = "unpublish"
+	bulkAddTag    = "addtag"
+	bulkRemoveTag = "removetag"
+	bulkToPage    = "topage"
+	bulkToPost    = "topost"
+	bulkDelete    = "delete"
+)
+
+// Filters for the status of posts in the post list.
+const (
+	statusDraft     = "draft"
+	statusPublished = "published"
+	statusPage      = "page"
+)
+
+// Sort orders for the post list. Each one shows the newest posts first.
+const (
+	sortTimestamp = "timestamp"
+	sortCreated   = "created"
+	sortUpdated   = "updated"
+)
+
+// validBulkAction returns true if the action can be applied to posts.
+func validBulkAction(action string) bool {
+	switch action {
+	case bulkPublish, bulkUnpublish, bulkAddTag, bulkRemoveTag, bulkToPage, bulkToPost, bulkDelete:
+		return true
+	}
+
+	return false
+}
+
+// applyBulkAction changes a post for one of the bulk actions other than
+// delete. The tag is only used to add or remove a tag. False is returned if
+// the post already matches so it doesn't need to be saved.
+func applyBulkAction(post *ambient.Post, action string, tag string, now time.Time) bool {
+	switch action {
+	case bulkPublish:
+		if post.Published {
+			return false
+		}
+		post.Published = true
+	case bulkUnpublish:
+		if !post.Published {
+			return false
+		}
+		post.Published = false
+	case bulkToPage:
+		if post.Page {
+			return false
+		}
+		post.Page = true
+	case bulkToPost:
+		if !post.Page {
+			return false
+		}
+		post.Page = false
+	case bulkAddTag:
+		if hasTag(post.Tags, tag) {
+			return false
+		}
+		post.Tags = append(post.Tags, ambient.Tag{Name: tag, Timestamp: now})
+	case bulkRemoveTag:
+		tags := make(ambient.TagList, 0, len(post.Tags))
+		for _, v := range post.Tags {
+			if v.Name != tag {
+				tags = append(tags, v)
+			}
+		}
+		if len(tags) == len(post.Tags) {
+			return false
+		}
+		post.Tags = tags
+	default:
+		return false
+	}
+
+	post.Updated = now
+	return true
+}
+
+// bulkSummary returns a sentence that describes how many of the selected
+// posts were changed by an action.
+func bulkSummary(action string, tag string, changed int, selected int) string {
+	var verb string
+	switch action {
+	case bulkPublish:
+		verb = "Published"
+	case bulkUnpublish:
+		verb = "Unpublished"
+	case bulkAddTag:
+		verb = fmt.Sprintf("Added tag '%v' to", tag)
+	case bulkRemoveTag:
+		verb = fmt.Sprintf("Removed tag '%v' from", tag)
+	case bulkToPage:
+		verb = "Converted to pages"
+	case bulkToPost:
+		verb = "Converted to posts"
+	case bulkDelete:
+		verb = "Moved to the trash"
+	}
+
+	msg := fmt.Sprintf("%v %v of %v selected posts.", verb, changed, selected)
+	if unchanged := selected - changed; unchanged > 0 {
+		msg += fmt.Sprintf(" %v already matched and were not changed.", unchanged)
+	}
+
+	return msg
+}
+
+// filterPosts returns the posts that match the status and tag. Empty filters
+// match all posts.
+func filterPosts(posts ambient.PostWithIDList, status string, tag string) ambient.PostWithIDList {
+	arr := make(ambient.PostWithIDList, 0, len(posts))
+	for _, v := range posts {
+		switch status {
+		case statusDraft:
+			if v.Published {
+				continue
+			}
+		case statusPublished:
+			if !v.Published {
+				continue
+			}
+		case statusPage:
+			if !v.Page {
+				continue
+			}
+		}
+
+		if len(tag) > 0 && !hasTag(v.Tags, tag) {
+			continue
+		}
+
+		arr = append(arr, v)
+	}
+
+	return arr
+}
+
+// sortPosts sorts the posts newest first by the field in the sort order. The
+// publish date is used if the sort order is not valid.
+func sortPosts(posts ambient.PostWithIDList, order string) {
+	field := func(p ambient.PostWithID) time.Time {
+		switch order {
+		case sortCreated:
+			return p.Created
+		case sortUpdated:
+			return p.Updated
+		}
+		return p.Timestamp
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return field(posts[i]).After(field(posts[j]))
+	})
+}
+
+// postTags returns the names of the tags used by the posts sorted by name.
+func postTags(posts ambient.PostWithIDList) []string {
+	seen := make(map[string]bool)
+	arr := make([]string, 0)
+	for _, v := range posts {
+		for _, t := range v.Tags {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				arr = append(arr, t.Name)
+			}
+		}
+	}
+
+	sort.Strings(arr)
+	return arr
+}
+
+// hasTag returns true if the list contains a tag with the name.
+func hasTag(tags ambient.TagList, name string) bool {
+	for _, v := range tags {
+		if v.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package bearblog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestApplyBulkAction(t *testing.T) {
+	now := time.Now()
+	post := ambient.Post{Tags: ambient.TagList{{Name: "go"}}}
+
+	tests := []struct {
+		action  string
+		tag     string
+		changed bool
+		check   func(ambient.Post) bool
+	}{
+		{bulkPublish, "", true, func(p ambient.Post) bool { return p.Published }},
+		{bulkPublish, "", false, func(p ambient.Post) bool { return p.Published }},
+		{bulkToPage, "", true, func(p ambient.Post) bool { return p.Page }},
+		{bulkToPost, "", true, func(p ambient.Post) bool { return !p.Page }},
+		{bulkAddTag, "go", false, func(p ambient.Post) bool { return len(p.Tags) == 1 }},
+		{bulkAddTag, "web", true, func(p ambient.Post) bool { return hasTag(p.Tags, "web") }},
+		{bulkRemoveTag, "go", true, func(p ambient.Post) bool { return !hasTag(p.Tags, "go") && len(p.Tags) == 1 }},
+		{bulkRemoveTag, "go", false, func(p ambient.Post) bool { return len(p.Tags) == 1 }},
+		{bulkUnpublish, "", true, func(p ambient.Post) bool { return !p.Published }},
+	}
+
+	for _, tt := range tests {
+		changed := applyBulkAction(&post, tt.action, tt.tag, now)
+		if changed != tt.changed || !tt.check(post) {
+			t.Errorf("applyBulkAction(%v, %q): got changed %v, post %+v", tt.action, tt.tag, changed, post)
+		}
+	}
+
+	if !post.Updated.Equal(now) {
+		t.Errorf("expected updated time to be set, got %v", post.Updated)
+	}
+}
+
+func TestFilterAndSortPosts(t *testing.T) {
+	now := time.Now()
+	posts := ambient.PostWithIDList{
+		{ID: "draft", Post: ambient.Post{Timestamp: now, Created: now.Add(-time.Hour), Tags: ambient.TagList{{Name: "go"}}}},
+		{ID: "post", Post: ambient.Post{Published: true, Timestamp: now.Add(-time.Hour), Created: now}},
+		{ID: "page", Post: ambient.Post{Published: true, Page: true, Timestamp: now.Add(-2 * time.Hour), Created: now.Add(-2 * time.Hour), Tags: ambient.TagList{{Name: "go"}}}},
+	}
+
+	ids := func(arr ambient.PostWithIDList) string {
+		s := ""
+		for _, v := range arr {
+			s += v.ID + " "
+		}
+		return s
+	}
+
+	tests := []struct {
+		status string
+		tag    string
+		want   string
+	}{
+		{"", "", "draft post page "},
+		{statusDraft, "", "draft "},
+		{statusPublished, "", "post page "},
+		{statusPage, "", "page "},
+		{"", "go", "draft page "},
+		{statusPublished, "go", "page "},
+	}
+
+	for _, tt := range tests {
+		if got := ids(filterPosts(posts, tt.status, tt.tag)); got != tt.want {
+			t.Errorf("filterPosts(%q, %q): got %q, want %q", tt.status, tt.tag, got, tt.want)
+		}
+	}
+
+	sorted := filterPosts(posts, "", "")
+	sortPosts(sorted, sortCreated)
+	if got := ids(sorted); got != "post draft page " {
+		t.Errorf("expected sort by created, got %q", got)
+	}
+
+	sortPosts(sorted, "")
+	if got := ids(sorted); got != "draft post page " {
+		t.Errorf("expected sort by publish date, got %q", got)
+	}
+}
+
+func TestBulkSummary(t *testing.T) {
+	if got := bulkSummary(bulkPublish, "", 2, 2); got != "Published 2 of 2 selected posts." {
+		t.Errorf("unexpected summary: %v", got)
+	}
+
+	want := "Added tag 'go' to 1 of 3 selected posts. 2 already matched and were not changed."
+	if got := bulkSummary(bulkAddTag, "go", 1, 3); got != want {
+		t.Errorf("unexpected summary: %v", got)
+	}
+}
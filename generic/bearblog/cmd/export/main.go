@@ -0,0 +1,191 @@
+// Package main renders a bearblog site to static files that can be served by
+// any static file server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/ambient/pkg/ambientapp"
+	"github.com/ambientkit/plugin/generic/bearblog"
+	"github.com/ambientkit/plugin/generic/bearcss"
+	"github.com/ambientkit/plugin/generic/robots"
+	"github.com/ambientkit/plugin/generic/rssfeed"
+	"github.com/ambientkit/plugin/generic/sitemap"
+	"github.com/ambientkit/plugin/logger/zaplogger"
+	"github.com/ambientkit/plugin/pkg/uuid"
+	"github.com/ambientkit/plugin/router/awayrouter"
+	"github.com/ambientkit/plugin/sessionmanager/scssession"
+	"github.com/ambientkit/plugin/storage/localstorage"
+	"github.com/ambientkit/plugin/templateengine/htmlengine"
+)
+
+func init() {
+	// Verbose logging with file name and line number.
+	log.SetFlags(log.Lshortfile)
+
+	// Set the time zone.
+	tz := os.Getenv("AMB_TIMEZONE")
+	if len(tz) > 0 {
+		os.Setenv("TZ", tz)
+	}
+}
+
+func main() {
+	sitePath := flag.String("site", "storage/site.bin", "path to the site storage file")
+	outDir := flag.String("out", "", "folder to write the site to")
+	zipPath := flag.String("zip", "", "zip file to write the site to")
+	flag.Parse()
+
+	if (len(*outDir) == 0) == (len(*zipPath) == 0) {
+		log.Fatalln("You must pass in either -out or -zip.")
+	}
+
+	// Work on a copy of the storage so plugins that save settings when they
+	// load don't change the site.
+	tmp, err := os.MkdirTemp("", "bearblog-export")
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	defer os.RemoveAll(tmp)
+
+	err = copyFile(*sitePath, filepath.Join(tmp, "site.bin"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	blog := bearblog.New(os.Getenv("AMB_PASSWORD_HASH"))
+	mux, err := handler(blog, tmp)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	blog.SetExportHandler(mux)
+
+	var n int
+	if len(*outDir) > 0 {
+		n, err = blog.ExportDir(*outDir)
+	} else {
+		n, err = exportZip(blog, *zipPath)
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	fmt.Printf("Exported %v files.\n", n)
+}
+
+// handler returns the handler of an app with the plugins that render the
+// public pages of the site.
+func handler(blog *bearblog.Plugin, storagePath string) (http.Handler, error) {
+	// The session key only needs to be set to keep the session manager happy
+	// since no one logs in.
+	key := os.Getenv("AMB_SESSION_KEY")
+	if len(key) == 0 {
+		key = uuid.EncodedString(32)
+	}
+	sessionManager := scssession.New(key)
+
+	sm := sitemap.New()
+	sm.AddPageSource(blog.SitemapPages)
+	sm.AddAlternateSource(blog.SitemapAlternates)
+	feed := rssfeed.New()
+	feed.AddCategorySource(blog.FeedCategories)
+	feed.AddAuthorSource(blog.FeedAuthors)
+	rb := robots.New()
+
+	plugins := &ambient.PluginLoader{
+		Router:         awayrouter.New(nil),
+		TemplateEngine: htmlengine.New(),
+		SessionManager: sessionManager,
+		// The plugins are trusted so the export works even if they aren't
+		// enabled on the site yet.
+		TrustedPlugins: map[string]bool{
+			"bearblog": true,
+			"bearcss":  true,
+			"sitemap":  true,
+			"rssfeed":  true,
+			"robots":   true,
+		},
+		Plugins: []ambient.Plugin{
+			bearcss.New(),
+			sm,
+			feed,
+			rb,
+		},
+		Middleware: []ambient.MiddlewarePlugin{
+			// Middleware - executes top to bottom.
+			sessionManager,
+			blog,
+		},
+	}
+
+	app, _, err := ambientapp.NewApp("bearblog-export", "1.0",
+		zaplogger.New(),
+		ambient.StoragePluginGroup{
+			Storage: localstorage.New(filepath.Join(storagePath, "site.bin"),
+				filepath.Join(storagePath, "session.bin")),
+		},
+		plugins)
+	if err != nil {
+		return nil, err
+	}
+	app.SetLogLevel(ambient.LogLevelError)
+
+	// Some plugins don't request the grants for their routes and assets
+	// because they are usually granted on the dashboard.
+	ps := app.PluginSystem()
+	for _, name := range []string{sm.PluginName(), feed.PluginName(), rb.PluginName()} {
+		for _, grant := range []ambient.Grant{ambient.GrantRouterRouteWrite, ambient.GrantSiteAssetWrite} {
+			err = ps.SetGrant(name, grant)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return app.Handler()
+}
+
+// exportZip writes the site to a zip file.
+func exportZip(blog *bearblog.Plugin, name string) (int, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n, err := blog.ExportZip(f)
+	if err != nil {
+		return n, err
+	}
+
+	return n, f.Close()
+}
+
+// copyFile copies the file at src to dst.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+
+	return out.Close()
+}
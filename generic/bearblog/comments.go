@@ -0,0 +1,288 @@
+package bearblog
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of a comment.
+const (
+	commentPending  = "pending"
+	commentApproved = "approved"
+	commentRejected = "rejected"
+)
+
+const (
+	// minCommentDelay is how long after the post is loaded a comment can be
+	// submitted. Bots usually submit forms right away.
+	minCommentDelay = 3 * time.Second
+	// maxCommentDelay is how long the comment form can be open before it
+	// expires.
+	maxCommentDelay = 24 * time.Hour
+	// maxCommentName is the longest name allowed on a comment.
+	maxCommentName = 100
+	// maxCommentLength is the longest comment allowed.
+	maxCommentLength = 5000
+	// maxPendingComments is the most comments that can wait for approval.
+	// New comments from readers are turned away until some are moderated.
+	maxPendingComments = 200
+	// maxReaderComments is the most comments a reader can leave from an IP
+	// address in each commentWindow.
+	maxReaderComments = 5
+	// commentWindow is how long comments from an IP address are counted.
+	commentWindow = 10 * time.Minute
+)
+
+var (
+	errCommentTooFast = errors.New("comment was submitted too quickly")
+	errCommentExpired = errors.New("comment form has expired")
+	errCommentToken   = errors.New("comment token is not valid")
+	errCommentsFull   = errors.New("too many comments are waiting for approval")
+)
+
+// comment is a comment from a reader on a post.
+type comment struct {
+	ID      string    `json:"id"`
+	PostID  string    `json:"postid"`
+	Name    string    `json:"name"`
+	Content string    `json:"content"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+}
+
+// validate returns messages that explain why a comment can't be saved.
+func (c comment) validate() []string {
+	arr := make([]string, 0)
+	if len(c.Name) == 0 {
+		arr = append(arr, "Name is required.")
+	} else if len(c.Name) > maxCommentName {
+		arr = append(arr, fmt.Sprintf("Name must be %v characters or less.", maxCommentName))
+	}
+
+	if len(c.Content) == 0 {
+		arr = append(arr, "Comment is required.")
+	} else if len(c.Content) > maxCommentLength {
+		arr = append(arr, fmt.Sprintf("Comment must be %v characters or less.", maxCommentLength))
+	}
+
+	return arr
+}
+
+// comments returns the comments on the post with the ID in the order they
+// were created.
+func (p *Plugin) comments(postID string) ([]comment, error) {
+	all := make(map[string][]comment)
+	err := p.loadData(dataComments, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all[postID], nil
+}
+
+// allComments returns the comments on all posts with the status, newest
+// first. An empty status returns all comments.
+func (p *Plugin) allComments(status string) ([]comment, error) {
+	all := make(map[string][]comment)
+	err := p.loadData(dataComments, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make([]comment, 0)
+	for _, comments := range all {
+		for _, c := range comments {
+			if len(status) == 0 || c.Status == status {
+				arr = append(arr, c)
+			}
+		}
+	}
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Created.After(arr[j].Created)
+	})
+
+	return arr, nil
+}
+
+// addComment saves a new comment. If the comment needs to be approved and
+// there are already too many comments waiting, errCommentsFull is returned.
+func (p *Plugin) addComment(c comment) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string][]comment)
+	err := p.loadData(dataComments, &all)
+	if err != nil {
+		return err
+	}
+
+	if c.Status == commentPending && pendingComments(all) >= maxPendingComments {
+		return errCommentsFull
+	}
+
+	all[c.PostID] = append(all[c.PostID], c)
+
+	return p.saveData(dataComments, all)
+}
+
+// pendingComments returns the number of comments waiting for approval.
+func pendingComments(all map[string][]comment) int {
+	count := 0
+	for _, comments := range all {
+		for _, c := range comments {
+			if c.Status == commentPending {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// moderateComments sets the status of the comments with the IDs. An empty
+// status deletes the comments. The number of comments changed is returned.
+func (p *Plugin) moderateComments(IDs []string, status string) (int, error) {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string][]comment)
+	err := p.loadData(dataComments, &all)
+	if err != nil {
+		return 0, err
+	}
+
+	selected := make(map[string]bool)
+	for _, v := range IDs {
+		selected[v] = true
+	}
+
+	changed := 0
+	for postID, comments := range all {
+		kept := make([]comment, 0, len(comments))
+		for _, c := range comments {
+			if selected[c.ID] {
+				changed++
+				if len(status) == 0 {
+					continue
+				}
+				c.Status = status
+			}
+			kept = append(kept, c)
+		}
+
+		if len(kept) == 0 {
+			delete(all, postID)
+		} else {
+			all[postID] = kept
+		}
+	}
+
+	if changed == 0 {
+		return 0, nil
+	}
+
+	return changed, p.saveData(dataComments, all)
+}
+
+// deleteComments removes the comments and the comments closed flag of a post.
+func (p *Plugin) deleteComments(postID string) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string][]comment)
+	err := p.loadData(dataComments, &all)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := all[postID]; ok {
+		delete(all, postID)
+		err = p.saveData(dataComments, all)
+		if err != nil {
+			return err
+		}
+	}
+
+	closed := make(map[string]bool)
+	err = p.loadData(dataClosed, &closed)
+	if err != nil {
+		return err
+	}
+
+	if !closed[postID] {
+		return nil
+	}
+	delete(closed, postID)
+
+	return p.saveData(dataClosed, closed)
+}
+
+// commentsClosed returns true if new comments aren't allowed on the post.
+func (p *Plugin) commentsClosed(postID string) (bool, error) {
+	closed := make(map[string]bool)
+	err := p.loadData(dataClosed, &closed)
+	if err != nil {
+		return false, err
+	}
+
+	return closed[postID], nil
+}
+
+// setCommentsClosed sets whether new comments are allowed on the post.
+func (p *Plugin) setCommentsClosed(postID string, value bool) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	closed := make(map[string]bool)
+	err := p.loadData(dataClosed, &closed)
+	if err != nil {
+		return err
+	}
+
+	if closed[postID] == value {
+		return nil
+	}
+
+	if value {
+		closed[postID] = true
+	} else {
+		delete(closed, postID)
+	}
+
+	return p.saveData(dataClosed, closed)
+}
+
+// commentToken returns a token for the comment form on a post that records
+// when the form was shown.
+func commentToken(key []byte, postID string, now time.Time) string {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	return ts + "." + signWithKey(key, "comment", postID, ts)
+}
+
+// checkCommentToken returns an error if the token wasn't created for the
+// post, or if the form was submitted too quickly or too late.
+func checkCommentToken(key []byte, postID string, token string, now time.Time) error {
+	arr := strings.SplitN(token, ".", 2)
+	if len(arr) != 2 || !hmac.Equal([]byte(arr[1]), []byte(signWithKey(key, "comment", postID, arr[0]))) {
+		return errCommentToken
+	}
+
+	i, err := strconv.ParseInt(arr[0], 10, 64)
+	if err != nil {
+		return errCommentToken
+	}
+
+	age := now.Sub(time.Unix(i, 0))
+	if age < minCommentDelay {
+		return errCommentTooFast
+	} else if age > maxCommentDelay {
+		return errCommentExpired
+	}
+
+	return nil
+}
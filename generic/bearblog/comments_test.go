@@ -0,0 +1,58 @@
+package bearblog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommentToken(t *testing.T) {
+	key := []byte("secret")
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := commentToken(key, "post1", now)
+
+	tests := []struct {
+		name     string
+		key      []byte
+		postID   string
+		token    string
+		now      time.Time
+		expected error
+	}{
+		{"valid", key, "post1", token, now.Add(time.Minute), nil},
+		{"too fast", key, "post1", token, now.Add(time.Second), errCommentTooFast},
+		{"expired", key, "post1", token, now.Add(maxCommentDelay + time.Second), errCommentExpired},
+		{"other post", key, "post2", token, now.Add(time.Minute), errCommentToken},
+		{"other key", []byte("other"), "post1", token, now.Add(time.Minute), errCommentToken},
+		{"malformed", key, "post1", "abc", now.Add(time.Minute), errCommentToken},
+	}
+
+	for _, tt := range tests {
+		if err := checkCommentToken(tt.key, tt.postID, tt.token, tt.now); err != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestCommentValidate(t *testing.T) {
+	if msgs := (comment{Name: "Jane", Content: "Nice post."}).validate(); len(msgs) != 0 {
+		t.Errorf("valid comment: unexpected messages: %v", msgs)
+	}
+	if msgs := (comment{}).validate(); len(msgs) != 2 {
+		t.Errorf("empty comment: expected 2 messages, got %v", msgs)
+	}
+	if msgs := (comment{Name: "Jane", Content: strings.Repeat("a", maxCommentLength+1)}).validate(); len(msgs) != 1 {
+		t.Errorf("long comment: expected 1 message, got %v", msgs)
+	}
+}
+
+func TestPendingComments(t *testing.T) {
+	all := map[string][]comment{
+		"post1": {{Status: commentPending}, {Status: commentApproved}},
+		"post2": {{Status: commentPending}, {Status: commentRejected}, {Status: commentPending}},
+	}
+
+	if got := pendingComments(all); got != 3 {
+		t.Errorf("expected 3, got %v", got)
+	}
+}
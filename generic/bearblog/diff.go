@@ -0,0 +1,115 @@
+package bearblog
+
+import (
+	"strings"
+)
+
+// diffLine is a single line in a line diff.
+type diffLine struct {
+	// Op is "+" for an added line, "-" for a removed line, or " " for a line
+	// that is in both.
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells is the largest table used to find the longest common
+// subsequence of lines. Larger changes are shown as every changed line being
+// removed and then added so a diff doesn't use too much memory or time.
+const maxDiffCells = 1000000
+
+// lineDiff returns the line by line changes required to turn a into b using
+// the longest common subsequence of lines. Lines that are the same at the
+// start and end are matched first.
+func lineDiff(a string, b string) []diffLine {
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+
+	prefix := 0
+	for prefix < len(linesA) && prefix < len(linesB) && linesA[prefix] == linesB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(linesA)-prefix && suffix < len(linesB)-prefix &&
+		linesA[len(linesA)-1-suffix] == linesB[len(linesB)-1-suffix] {
+		suffix++
+	}
+
+	arr := make([]diffLine, 0, len(linesA)+len(linesB))
+	for _, v := range linesA[:prefix] {
+		arr = append(arr, diffLine{Op: " ", Text: v})
+	}
+	arr = append(arr, lcsDiff(linesA[prefix:len(linesA)-suffix], linesB[prefix:len(linesB)-suffix])...)
+	for _, v := range linesA[len(linesA)-suffix:] {
+		arr = append(arr, diffLine{Op: " ", Text: v})
+	}
+
+	return arr
+}
+
+// lcsDiff returns the changes required to turn linesA into linesB. If the
+// table would be larger than maxDiffCells, all of linesA are removed and all
+// of linesB are added instead.
+func lcsDiff(linesA []string, linesB []string) []diffLine {
+	arr := make([]diffLine, 0)
+	if len(linesA)*len(linesB) > maxDiffCells {
+		for _, v := range linesA {
+			arr = append(arr, diffLine{Op: "-", Text: v})
+		}
+		for _, v := range linesB {
+			arr = append(arr, diffLine{Op: "+", Text: v})
+		}
+		return arr
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// linesA[i:] and linesB[j:].
+	lcs := make([][]int, len(linesA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(linesB)+1)
+	}
+	for i := len(linesA) - 1; i >= 0; i-- {
+		for j := len(linesB) - 1; j >= 0; j-- {
+			if linesA[i] == linesB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(linesA) && j < len(linesB) {
+		switch {
+		case linesA[i] == linesB[j]:
+			arr = append(arr, diffLine{Op: " ", Text: linesA[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			arr = append(arr, diffLine{Op: "-", Text: linesA[i]})
+			i++
+		default:
+			arr = append(arr, diffLine{Op: "+", Text: linesB[j]})
+			j++
+		}
+	}
+	for ; i < len(linesA); i++ {
+		arr = append(arr, diffLine{Op: "-", Text: linesA[i]})
+	}
+	for ; j < len(linesB); j++ {
+		arr = append(arr, diffLine{Op: "+", Text: linesB[j]})
+	}
+
+	return arr
+}
+
+// splitLines returns the lines in a string with unix line endings.
+func splitLines(s string) []string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	if len(s) == 0 {
+		return []string{}
+	}
+
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,57 @@
+package bearblog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	diff := lineDiff("a\nb\nc", "a\nc\nd")
+
+	expected := []diffLine{
+		{Op: " ", Text: "a"},
+		{Op: "-", Text: "b"},
+		{Op: " ", Text: "c"},
+		{Op: "+", Text: "d"},
+	}
+
+	if len(diff) != len(expected) {
+		t.Fatalf("expected %v lines, got %v: %v", len(expected), len(diff), diff)
+	}
+
+	for i := range expected {
+		if diff[i] != expected[i] {
+			t.Errorf("line %v: expected %v, got %v", i, expected[i], diff[i])
+		}
+	}
+}
+
+func TestLineDiffEmpty(t *testing.T) {
+	diff := lineDiff("", "a\r\nb")
+	if len(diff) != 2 || diff[0].Op != "+" || diff[1].Text != "b" {
+		t.Errorf("unexpected diff: %v", diff)
+	}
+}
+
+func TestLineDiffLarge(t *testing.T) {
+	a := make([]string, 0)
+	b := make([]string, 0)
+	for i := 0; i < 2000; i++ {
+		a = append(a, fmt.Sprintf("a%v", i))
+		b = append(b, fmt.Sprintf("b%v", i))
+	}
+
+	// The same first and last lines are kept and the rest is too large to
+	// compare line by line.
+	diff := lineDiff("start\n"+strings.Join(a, "\n")+"\nend", "start\n"+strings.Join(b, "\n")+"\nend")
+	if len(diff) != 4002 {
+		t.Fatalf("expected 4002 lines, got %v", len(diff))
+	}
+	if diff[0] != (diffLine{Op: " ", Text: "start"}) || diff[4001] != (diffLine{Op: " ", Text: "end"}) {
+		t.Errorf("expected the same lines to be kept: %v %v", diff[0], diff[4001])
+	}
+	if diff[1] != (diffLine{Op: "-", Text: "a0"}) || diff[2001] != (diffLine{Op: "+", Text: "b0"}) {
+		t.Errorf("expected the lines to be removed then added: %v %v", diff[1], diff[2001])
+	}
+}
@@ -0,0 +1,95 @@
+package bearblog
+
+import (
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+// draftNew is the prefix of the draft key for a post that hasn't been created
+// yet. Each user has their own draft for a new post.
+const draftNew = "new:"
+
+// draft is an autosaved copy of the post form. It's kept separate from the
+// post so the published content doesn't change until the form is saved.
+type draft struct {
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	Canonical string    `json:"canonical"`
+	Date      string    `json:"date"`
+	Content   string    `json:"content"`
+	Tags      string    `json:"tags"`
+	Page      bool      `json:"page"`
+	Published bool      `json:"published"`
+	Username  string    `json:"username"`
+	Saved     time.Time `json:"saved"`
+}
+
+// draftKey returns the key of the draft for the post with the ID or for a new
+// post by the user if the ID is empty.
+func draftKey(ID string, username string) string {
+	if len(ID) == 0 {
+		return draftNew + username
+	}
+
+	return ID
+}
+
+// apply returns the post with the values from the draft.
+func (d draft) apply(post ambient.Post) ambient.Post {
+	post.Title = d.Title
+	post.URL = d.Slug
+	post.Canonical = d.Canonical
+	post.Content = d.Content
+	post.Tags = post.Tags.Split(d.Tags)
+	post.Page = d.Page
+	post.Published = d.Published
+	return post
+}
+
+// draft returns the draft with the key.
+func (p *Plugin) draft(key string) (draft, bool, error) {
+	all := make(map[string]draft)
+	err := p.loadData(dataDrafts, &all)
+	if err != nil {
+		return draft{}, false, err
+	}
+
+	d, ok := all[key]
+	return d, ok, nil
+}
+
+// saveDraft creates or replaces the draft with the key.
+func (p *Plugin) saveDraft(key string, d draft) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]draft)
+	err := p.loadData(dataDrafts, &all)
+	if err != nil {
+		return err
+	}
+
+	all[key] = d
+
+	return p.saveData(dataDrafts, all)
+}
+
+// deleteDraft removes the draft with the key.
+func (p *Plugin) deleteDraft(key string) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]draft)
+	err := p.loadData(dataDrafts, &all)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := all[key]; !ok {
+		return nil
+	}
+	delete(all, key)
+
+	return p.saveData(dataDrafts, all)
+}
@@ -0,0 +1,29 @@
+package bearblog
+
+import (
+	"testing"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestDraftKey(t *testing.T) {
+	if got := draftKey("", "jane"); got != "new:jane" {
+		t.Errorf("draftKey for a new post: expected %q, got %q", "new:jane", got)
+	}
+	if got := draftKey("abc", "jane"); got != "abc" {
+		t.Errorf("draftKey for a post: expected %q, got %q", "abc", got)
+	}
+}
+
+func TestDraftApply(t *testing.T) {
+	post := ambient.Post{Title: "Old", URL: "old", Content: "old content", Published: true}
+	d := draft{Title: "New", Slug: "new", Content: "new content", Tags: "go, web"}
+
+	got := d.apply(post)
+	if got.Title != "New" || got.URL != "new" || got.Content != "new content" || got.Published {
+		t.Errorf("apply: fields not copied from draft: %+v", got)
+	}
+	if got.Tags.String() != "go,web" {
+		t.Errorf("apply: expected tags %q, got %q", "go,web", got.Tags.String())
+	}
+}
@@ -0,0 +1,118 @@
+var editorForm = document.querySelector("form.post-form[data-draft-url]");
+if (editorForm) {
+    var draftStatus = document.getElementById("id_draft_status");
+    var draftInterval = 5000;
+    var lastDraft = draftSnapshot();
+    var draftQueue = Promise.resolve();
+    var submitting = false;
+
+    // Save a draft every few seconds when the form has changed.
+    setInterval(function () {
+        var snapshot = draftSnapshot();
+        if (submitting || snapshot === lastDraft) {
+            return;
+        }
+
+        sendDraft(new FormData(editorForm)).then(function (result) {
+            if (result.error) {
+                draftStatus.textContent = "Draft not saved: " + result.error;
+                return;
+            }
+            lastDraft = snapshot;
+            draftStatus.textContent = "Draft saved at " + new Date(result.saved).toLocaleTimeString() + ".";
+        });
+    }, draftInterval);
+
+    editorForm.addEventListener("submit", function () {
+        submitting = true;
+    });
+
+    var discardLink = document.getElementById("id_draft_discard");
+    if (discardLink) {
+        discardLink.addEventListener("click", function (e) {
+            e.preventDefault();
+
+            var data = new FormData();
+            data.append("discard", "true");
+            sendDraft(data).then(function (result) {
+                if (result.error) {
+                    alert("Draft not discarded: " + result.error);
+                    return;
+                }
+                document.getElementById("id_draft_notice").hidden = true;
+            });
+        });
+    }
+
+    var previewToggle = document.getElementById("id_preview_toggle");
+    var preview = document.getElementById("id_preview");
+    previewToggle.addEventListener("click", function (e) {
+        e.preventDefault();
+
+        if (!preview.hidden) {
+            preview.hidden = true;
+            previewToggle.textContent = "Show preview";
+            return;
+        }
+
+        var data = new FormData();
+        data.append("token", editorForm.dataset.previewToken);
+        data.append("content", document.getElementById("id_content").value);
+
+        postJSON(editorForm.dataset.previewUrl, data).then(function (result) {
+            if (result.token) {
+                editorForm.dataset.previewToken = result.token;
+            }
+            if (result.error) {
+                alert("Preview failed: " + result.error);
+                return;
+            }
+            preview.innerHTML = result.html;
+            preview.hidden = false;
+            previewToggle.textContent = "Hide preview";
+        });
+    });
+}
+
+// draftSnapshot returns the form values without the token so changes can be
+// detected.
+function draftSnapshot() {
+    var data = new FormData(editorForm);
+    data.delete("token");
+    return new URLSearchParams(data).toString();
+}
+
+// sendDraft posts to the draft URL. Requests are sent one at a time since
+// each response has the token for the next one.
+function sendDraft(data) {
+    draftQueue = draftQueue.then(function () {
+        data.set("token", editorForm.dataset.draftToken);
+        return postJSON(editorForm.dataset.draftUrl, data).then(function (result) {
+            if (result.token) {
+                editorForm.dataset.draftToken = result.token;
+            }
+            return result;
+        });
+    });
+    return draftQueue;
+}
+
+// postJSON posts the form data and returns the JSON response. A response that
+// isn't JSON means the session has expired and the login page was returned.
+function postJSON(url, data) {
+    return fetch(url, {
+        method: "POST",
+        body: data,
+        headers: { "Accept": "application/json" },
+        credentials: "same-origin",
+    })
+        .then(function (resp) {
+            if ((resp.headers.get("Content-Type") || "").indexOf("application/json") === -1) {
+                return { error: "session has expired, log in again to load the last draft" };
+            }
+            return resp.json();
+        })
+        .catch(function () {
+            return { error: "server could not be reached" };
+        });
+}
@@ -0,0 +1,47 @@
+var uploadInput = document.getElementById("id_upload");
+if (uploadInput) {
+    uploadInput.addEventListener("change", function () {
+        if (!uploadInput.files.length) {
+            return;
+        }
+
+        var data = new FormData();
+        data.append("token", uploadInput.dataset.token);
+        data.append("file", uploadInput.files[0]);
+        uploadInput.disabled = true;
+
+        fetch(uploadInput.dataset.url, {
+            method: "POST",
+            body: data,
+            headers: { "Accept": "application/json" },
+            credentials: "same-origin",
+        })
+            .then(function (resp) { return resp.json(); })
+            .then(function (result) {
+                // Tokens can only be used once so keep the new one.
+                if (result.token) {
+                    uploadInput.dataset.token = result.token;
+                }
+                if (result.error) {
+                    alert("Upload failed: " + result.error);
+                    return;
+                }
+                insertAtCursor(document.getElementById("id_content"), result.markdown);
+            })
+            .catch(function () {
+                alert("Upload failed.");
+            })
+            .finally(function () {
+                uploadInput.disabled = false;
+                uploadInput.value = "";
+            });
+    });
+}
+
+function insertAtCursor(el, text) {
+    var start = el.selectionStart;
+    var end = el.selectionEnd;
+    el.value = el.value.substring(0, start) + text + el.value.substring(end);
+    el.selectionStart = el.selectionEnd = start + text.length;
+    el.focus();
+}
@@ -0,0 +1,20 @@
+package bearblog
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeJSON sends a JSON response with a status code. The toolkit JSON
+// helper always responds with a 200 so it can't be used for errors.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
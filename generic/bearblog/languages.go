@@ -0,0 +1,391 @@
+package bearblog
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/generic/sitemap"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// defaultLanguage is used when the language setting is missing.
+const defaultLanguage = "en"
+
+// langPattern is a language code like "de" or "en-gb".
+var langPattern = regexp.MustCompile(`^[a-z]{2,3}(?:-[a-z0-9]{2,8})*$`)
+
+// postLanguage is the language of a post and the group of posts that are
+// translations of each other.
+type postLanguage struct {
+	Lang string `json:"lang"`
+	// Group is the ID of the post the others in the group are translations
+	// of. It's empty for that post.
+	Group string `json:"group,omitempty"`
+}
+
+// translation is a version of a post in a language.
+type translation struct {
+	ID    string `json:"id"`
+	Lang  string `json:"lang"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// languageOption is a language that posts can be filtered by.
+type languageOption struct {
+	Lang string `json:"lang"`
+	Name string `json:"name"`
+}
+
+// normalizeLang returns a language code in lowercase with hyphens.
+func normalizeLang(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "_", "-")
+}
+
+// languageName returns the name of a language in the language itself.
+func languageName(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return lang
+	}
+
+	name := display.Self.Name(tag)
+	if len(name) == 0 {
+		return lang
+	}
+
+	return name
+}
+
+// siteLanguage returns the language of posts that don't set one.
+func (p *Plugin) siteLanguage() string {
+	s, err := p.Site.PluginSettingString(Language)
+	if err != nil {
+		p.Log.Warn("bearblog: error getting language: %v", err.Error())
+		return defaultLanguage
+	}
+
+	lang := normalizeLang(s)
+	if !langPattern.MatchString(lang) {
+		return defaultLanguage
+	}
+
+	return lang
+}
+
+// postLanguages returns the languages of posts mapped to the post ID. Posts
+// that use the site language and aren't translated are not included.
+func (p *Plugin) postLanguages() (map[string]postLanguage, error) {
+	all := make(map[string]postLanguage)
+	err := p.loadData(dataLanguages, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// postLanguage returns the language of a post.
+func (p *Plugin) postLanguage(ID string) (postLanguage, error) {
+	all, err := p.postLanguages()
+	if err != nil {
+		return postLanguage{}, err
+	}
+
+	return all[ID], nil
+}
+
+// setPostLanguage sets the language of a post and the post it's a
+// translation of. An empty language and group removes the post.
+func (p *Plugin) setPostLanguage(ID string, pl postLanguage) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]postLanguage)
+	err := p.loadData(dataLanguages, &all)
+	if err != nil {
+		return err
+	}
+
+	if pl == (postLanguage{}) {
+		if _, ok := all[ID]; !ok {
+			return nil
+		}
+		delete(all, ID)
+	} else {
+		all[ID] = pl
+	}
+
+	return p.saveData(dataLanguages, all)
+}
+
+// translationGroup returns the group of the post with the ID. Posts that
+// aren't a translation of another post are in the group named after their
+// own ID.
+func translationGroup(ID string, langs map[string]postLanguage) string {
+	if g := langs[ID].Group; len(g) > 0 {
+		return g
+	}
+
+	return ID
+}
+
+// langOf returns the language of the post with the ID.
+func langOf(ID string, langs map[string]postLanguage, siteLang string) string {
+	if l := langs[ID].Lang; len(l) > 0 {
+		return l
+	}
+
+	return siteLang
+}
+
+// languageFormValues returns the language of the post with the ID from the
+// post form. A message is returned if the values can't be used.
+func (p *Plugin) languageFormValues(r *http.Request, ID string) (postLanguage, string, error) {
+	pl := postLanguage{
+		Lang: normalizeLang(r.FormValue("lang")),
+	}
+	if len(pl.Lang) > 0 && !langPattern.MatchString(pl.Lang) {
+		return pl, fmt.Sprintf("Language '%v' should be a code like 'en' or 'de-at'.", pl.Lang), nil
+	}
+
+	slug := strings.TrimSpace(r.FormValue("translation_of"))
+	if len(slug) == 0 {
+		return pl, "", nil
+	}
+
+	original, err := p.postBySlug(slug)
+	if err != nil || original.ID == ID {
+		return pl, fmt.Sprintf("Translation of '%v' should be the permalink of another post.", slug), nil
+	}
+
+	langs, err := p.postLanguages()
+	if err != nil {
+		return pl, "", err
+	}
+
+	siteLang := p.siteLanguage()
+	pl.Group = translationGroup(original.ID, langs)
+	lang := pl.Lang
+	if len(lang) == 0 {
+		lang = siteLang
+	}
+
+	for postID := range langs {
+		if postID != ID && langs[postID].Group == ID {
+			return pl, "Translation of can't be set because other posts are translations of this one.", nil
+		}
+	}
+
+	postsAndPages, err := p.postsAndPages(false)
+	if err != nil {
+		return pl, "", err
+	}
+
+	for _, v := range postsAndPages {
+		if v.ID != ID && translationGroup(v.ID, langs) == pl.Group && langOf(v.ID, langs, siteLang) == lang {
+			return pl, fmt.Sprintf("Post '%v' is already the '%v' translation.", v.URL, lang), nil
+		}
+	}
+
+	return pl, "", nil
+}
+
+// translations returns the other versions of the post with the ID sorted by
+// language. Only versions in posts are returned so they can be limited to the
+// posts readers can find.
+func (p *Plugin) translations(ID string, posts ambient.PostWithIDList) ([]translation, error) {
+	langs, err := p.postLanguages()
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make([]translation, 0)
+	siteLang := p.siteLanguage()
+	group := translationGroup(ID, langs)
+	for _, v := range posts {
+		if v.ID == ID || translationGroup(v.ID, langs) != group {
+			continue
+		}
+
+		lang := langOf(v.ID, langs, siteLang)
+		arr = append(arr, translation{
+			ID:    v.ID,
+			Lang:  lang,
+			Name:  languageName(lang),
+			URL:   v.URL,
+			Title: v.Title,
+		})
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Lang < arr[j].Lang
+	})
+
+	return arr, nil
+}
+
+// translationVars sets the variables for the language switcher of a post
+// and the alternate links for search engines.
+func (p *Plugin) translationVars(vars map[string]interface{}, post ambient.PostWithID) error {
+	vars["translations"] = []translation{}
+	vars["hreflangs"] = []translation{}
+
+	posts, err := p.publicPosts()
+	if err != nil {
+		return err
+	}
+
+	arr, err := p.translations(post.ID, posts)
+	if err != nil || len(arr) == 0 {
+		return err
+	}
+	vars["translations"] = arr
+
+	siteURL, err := p.Site.FullURL()
+	if err != nil {
+		return err
+	}
+
+	pl, err := p.postLanguage(post.ID)
+	if err != nil {
+		return err
+	}
+
+	lang := pl.Lang
+	if len(lang) == 0 {
+		lang = p.siteLanguage()
+	}
+
+	// The alternate links include the post itself.
+	hreflangs := []translation{{ID: post.ID, Lang: lang, URL: siteURL + "/" + post.URL}}
+	for _, v := range arr {
+		v.URL = siteURL + "/" + v.URL
+		hreflangs = append(hreflangs, v)
+	}
+	vars["hreflangs"] = hreflangs
+
+	return nil
+}
+
+// publicPosts returns the live posts and pages that aren't password
+// protected.
+func (p *Plugin) publicPosts() (ambient.PostWithIDList, error) {
+	postsAndPages, err := p.livePostsAndPages()
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := p.postAccesses()
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make(ambient.PostWithIDList, 0, len(postsAndPages))
+	for _, v := range postsAndPages {
+		if !accesses[v.ID].protected() {
+			arr = append(arr, v)
+		}
+	}
+
+	return arr, nil
+}
+
+// languageOptions returns the languages of the posts sorted by code. Nothing
+// is returned if all the posts are in the same language.
+func (p *Plugin) languageOptions(posts ambient.PostWithIDList) ([]languageOption, error) {
+	langs, err := p.postLanguages()
+	if err != nil {
+		return nil, err
+	}
+
+	siteLang := p.siteLanguage()
+	found := make(map[string]bool)
+	arr := make([]languageOption, 0)
+	for _, v := range posts {
+		lang := langOf(v.ID, langs, siteLang)
+		if !found[lang] {
+			found[lang] = true
+			arr = append(arr, languageOption{Lang: lang, Name: languageName(lang)})
+		}
+	}
+
+	if len(arr) < 2 {
+		return []languageOption{}, nil
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Lang < arr[j].Lang
+	})
+
+	return arr, nil
+}
+
+// inLanguage returns a function that reports if the post with the ID is in
+// the language. Every post is in an empty language.
+func (p *Plugin) inLanguage(lang string) (func(ID string) bool, error) {
+	if len(lang) == 0 {
+		return func(string) bool { return true }, nil
+	}
+
+	langs, err := p.postLanguages()
+	if err != nil {
+		return nil, err
+	}
+
+	siteLang := p.siteLanguage()
+	return func(ID string) bool {
+		return langOf(ID, langs, siteLang) == lang
+	}, nil
+}
+
+// SitemapAlternates returns the language versions of translated posts so
+// they can be linked in the sitemap plugin with AddAlternateSource.
+func (p *Plugin) SitemapAlternates() (map[string][]sitemap.Alternate, error) {
+	posts, err := p.publicPosts()
+	if err != nil {
+		return nil, err
+	}
+
+	langs, err := p.postLanguages()
+	if err != nil {
+		return nil, err
+	}
+
+	return translationAlternates(posts, langs, p.siteLanguage()), nil
+}
+
+// translationAlternates returns the language versions of the posts mapped to
+// the path of each version. Posts without a translation are left out.
+func translationAlternates(posts ambient.PostWithIDList, langs map[string]postLanguage, siteLang string) map[string][]sitemap.Alternate {
+	groups := make(map[string][]sitemap.Alternate)
+	for _, v := range posts {
+		group := translationGroup(v.ID, langs)
+		groups[group] = append(groups[group], sitemap.Alternate{
+			Lang: langOf(v.ID, langs, siteLang),
+			Path: "/" + v.URL,
+		})
+	}
+
+	m := make(map[string][]sitemap.Alternate)
+	for _, arr := range groups {
+		if len(arr) < 2 {
+			continue
+		}
+
+		sort.Slice(arr, func(i, j int) bool {
+			return arr[i].Lang < arr[j].Lang
+		})
+		for _, v := range arr {
+			m[v.Path] = arr
+		}
+	}
+
+	return m
+}
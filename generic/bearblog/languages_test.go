@@ -0,0 +1,102 @@
+package bearblog
+
+import (
+	"testing"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestNormalizeLang(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+		valid    bool
+	}{
+		{"de", "de", true},
+		{" EN_gb ", "en-gb", true},
+		{"de-AT", "de-at", true},
+		{"english", "english", false},
+		{"e", "e", false},
+		{"en-", "en-", false},
+	}
+
+	for _, tt := range tests {
+		got := normalizeLang(tt.in)
+		if got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.in, tt.expected, got)
+		}
+		if valid := langPattern.MatchString(got); valid != tt.valid {
+			t.Errorf("%v: expected valid %v, got %v", tt.in, tt.valid, valid)
+		}
+	}
+}
+
+func TestLanguageName(t *testing.T) {
+	tests := map[string]string{
+		"de":  "Deutsch",
+		"en":  "English",
+		"fr":  "français",
+		"zz!": "zz!",
+	}
+
+	for lang, expected := range tests {
+		if got := languageName(lang); got != expected {
+			t.Errorf("%v: expected %v, got %v", lang, expected, got)
+		}
+	}
+}
+
+func TestTranslationGroup(t *testing.T) {
+	langs := map[string]postLanguage{
+		"de": {Lang: "de", Group: "en"},
+		"fr": {Lang: "fr", Group: "en"},
+		"es": {Lang: "es"},
+	}
+
+	tests := []struct {
+		ID    string
+		group string
+		lang  string
+	}{
+		{"en", "en", "en"},
+		{"de", "en", "de"},
+		{"fr", "en", "fr"},
+		{"es", "es", "es"},
+		{"other", "other", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := translationGroup(tt.ID, langs); got != tt.group {
+			t.Errorf("%v: expected group %v, got %v", tt.ID, tt.group, got)
+		}
+		if got := langOf(tt.ID, langs, "en"); got != tt.lang {
+			t.Errorf("%v: expected language %v, got %v", tt.ID, tt.lang, got)
+		}
+	}
+}
+
+func TestTranslationAlternates(t *testing.T) {
+	posts := ambient.PostWithIDList{
+		{ID: "1", Post: ambient.Post{URL: "hello"}},
+		{ID: "2", Post: ambient.Post{URL: "hallo"}},
+		{ID: "3", Post: ambient.Post{URL: "alone"}},
+	}
+	langs := map[string]postLanguage{
+		"2": {Lang: "de", Group: "1"},
+	}
+
+	m := translationAlternates(posts, langs, "en")
+	if len(m) != 2 {
+		t.Fatalf("expected alternates for the 2 translated posts, got %v", m)
+	}
+	if _, ok := m["/alone"]; ok {
+		t.Errorf("expected no alternates for a post without translations")
+	}
+
+	for _, path := range []string{"/hello", "/hallo"} {
+		arr := m[path]
+		if len(arr) != 2 || arr[0].Lang != "de" || arr[0].Path != "/hallo" || arr[1].Lang != "en" || arr[1].Path != "/hello" {
+			t.Errorf("%v: expected both languages sorted, got %v", path, arr)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package bearblog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLoginFailures is the number of failed logins allowed before a lockout.
+	maxLoginFailures = 5
+	// loginFailureWindow is how long failed logins are counted towards a
+	// lockout.
+	loginFailureWindow = time.Hour
+	// minLoginLockout is the length of the first lockout. Each lockout after
+	// that is twice as long as the one before.
+	minLoginLockout = time.Minute
+	// maxLoginLockout is the longest lockout.
+	maxLoginLockout = 24 * time.Hour
+)
+
+// loginAttempts are the failed logins for an IP address or username.
+type loginAttempts struct {
+	failures    int
+	lockouts    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// lockout is an IP address or username that can't login until a time.
+type lockout struct {
+	Key   string    `json:"key"`
+	Until time.Time `json:"until"`
+}
+
+// loginLimiter locks out IP addresses and usernames after too many failed
+// logins. It also limits password attempts on protected posts from each IP
+// address. The state is kept in memory so it's reset when the app restarts.
+type loginLimiter struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]*loginAttempts
+}
+
+// newLoginLimiter returns a login limiter without any failed logins.
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		now:     time.Now,
+		entries: make(map[string]*loginAttempts),
+	}
+}
+
+// locked returns how long until all of the keys can login again. Zero is
+// returned if none of them are locked out.
+func (l *loginLimiter) locked(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var remaining time.Duration
+	for _, k := range keys {
+		if e, ok := l.entries[k]; ok && e.lockedUntil.After(now) {
+			if d := e.lockedUntil.Sub(now); d > remaining {
+				remaining = d
+			}
+		}
+	}
+
+	return remaining
+}
+
+// fail records a failed login for each of the keys and locks out the ones
+// that have too many failures. It returns true if one of the keys was locked
+// out by this failure.
+func (l *loginLimiter) fail(keys ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	locked := false
+	for _, k := range keys {
+		e, ok := l.entries[k]
+		if !ok {
+			e = &loginAttempts{}
+			l.entries[k] = e
+		}
+
+		if now.Sub(e.lastFailure) > loginFailureWindow {
+			e.failures = 0
+		}
+		e.failures++
+		e.lastFailure = now
+
+		if e.failures >= maxLoginFailures {
+			e.failures = 0
+			e.lockouts++
+			e.lockedUntil = now.Add(lockoutDuration(e.lockouts))
+			locked = true
+		}
+	}
+
+	return locked
+}
+
+// succeed clears the failed logins for each of the keys.
+func (l *loginLimiter) succeed(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, k := range keys {
+		delete(l.entries, k)
+	}
+}
+
+// unlock clears the lockout and failed logins for a key. It returns false if
+// the key isn't locked out.
+func (l *loginLimiter) unlock(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok || !e.lockedUntil.After(l.now()) {
+		return false
+	}
+
+	delete(l.entries, key)
+	return true
+}
+
+// lockouts returns the keys that are locked out, sorted by the time the
+// lockout ends.
+func (l *loginLimiter) lockouts() []lockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	arr := make([]lockout, 0)
+	for k, e := range l.entries {
+		if e.lockedUntil.After(now) {
+			arr = append(arr, lockout{Key: k, Until: e.lockedUntil})
+		}
+	}
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Until.Before(arr[j].Until)
+	})
+
+	return arr
+}
+
+// prune removes keys that aren't locked out and haven't failed recently so
+// memory doesn't grow without limit.
+func (l *loginLimiter) prune(now time.Time) {
+	for k, e := range l.entries {
+		if now.After(e.lockedUntil) && now.Sub(e.lastFailure) > maxLoginLockout {
+			delete(l.entries, k)
+		}
+	}
+}
+
+// lockoutDuration returns the length of a lockout that doubles each time up
+// to the maximum.
+func lockoutDuration(lockouts int) time.Duration {
+	d := minLoginLockout
+	for i := 1; i < lockouts; i++ {
+		d *= 2
+		if d >= maxLoginLockout {
+			return maxLoginLockout
+		}
+	}
+
+	return d
+}
+
+// rateWindow is the number of times a key was used since the window started.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter allows each key to be used a number of times in a window of
+// time. Unlike the login limiter, there are no lockouts so a key can be used
+// again once the window is over. The state is kept in memory so it's reset
+// when the app restarts.
+type rateLimiter struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	limit   int
+	window  time.Duration
+	entries map[string]*rateWindow
+}
+
+// newRateLimiter returns a rate limiter that allows each key to be used limit
+// times in each window.
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		now:     time.Now,
+		limit:   limit,
+		window:  window,
+		entries: make(map[string]*rateWindow),
+	}
+}
+
+// wait returns how long until the key can be used again. Zero is returned if
+// it can be used now.
+func (l *rateLimiter) wait(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	e, ok := l.entries[key]
+	if !ok || e.count < l.limit || now.Sub(e.start) >= l.window {
+		return 0
+	}
+
+	return e.start.Add(l.window).Sub(now)
+}
+
+// add counts a use of the key. A new window is started if the last one is
+// over.
+func (l *rateLimiter) add(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &rateWindow{start: now}
+		l.entries[key] = e
+	}
+	e.count++
+}
+
+// prune removes the keys with windows that are over so memory doesn't grow
+// without limit.
+func (l *rateLimiter) prune(now time.Time) {
+	for k, e := range l.entries {
+		if now.Sub(e.start) >= l.window {
+			delete(l.entries, k)
+		}
+	}
+}
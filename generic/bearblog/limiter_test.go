@@ -0,0 +1,111 @@
+package bearblog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < maxLoginFailures-1; i++ {
+		l.fail("ip:1.2.3.4", "user:jane")
+	}
+	if d := l.locked("ip:1.2.3.4", "user:jane"); d != 0 {
+		t.Fatalf("locked before the limit: %v", d)
+	}
+
+	if !l.fail("ip:1.2.3.4", "user:jane") {
+		t.Fatal("fail: expected the lockout to start")
+	}
+	if d := l.locked("user:jane"); d != minLoginLockout {
+		t.Fatalf("first lockout: expected %v, got %v", minLoginLockout, d)
+	}
+	if got := len(l.lockouts()); got != 2 {
+		t.Fatalf("lockouts: expected 2, got %v", got)
+	}
+
+	// The second lockout is twice as long.
+	now = now.Add(minLoginLockout)
+	for i := 0; i < maxLoginFailures; i++ {
+		l.fail("user:jane")
+	}
+	if d := l.locked("user:jane"); d != 2*minLoginLockout {
+		t.Fatalf("second lockout: expected %v, got %v", 2*minLoginLockout, d)
+	}
+
+	if !l.unlock("user:jane") {
+		t.Fatal("unlock: expected key to be locked")
+	}
+	if d := l.locked("user:jane"); d != 0 {
+		t.Fatalf("locked after unlock: %v", d)
+	}
+
+	l.succeed("ip:1.2.3.4")
+	if got := len(l.lockouts()); got != 0 {
+		t.Fatalf("lockouts after success: expected 0, got %v", got)
+	}
+}
+
+func TestLoginLimiterWindow(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	// Failures spread out over more than the window don't add up.
+	for i := 0; i < maxLoginFailures*2; i++ {
+		l.fail("user:jane")
+		now = now.Add(loginFailureWindow + time.Second)
+	}
+	if d := l.locked("user:jane"); d != 0 {
+		t.Fatalf("locked by old failures: %v", d)
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		20: maxLoginLockout,
+	}
+
+	for lockouts, expected := range tests {
+		if got := lockoutDuration(lockouts); got != expected {
+			t.Errorf("lockoutDuration(%v): expected %v, got %v", lockouts, expected, got)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(3, 10*time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if d := l.wait("1.2.3.4"); d != 0 {
+			t.Fatalf("limited before the limit: %v", d)
+		}
+		l.add("1.2.3.4")
+	}
+
+	now = now.Add(4 * time.Minute)
+	if d := l.wait("1.2.3.4"); d != 6*time.Minute {
+		t.Fatalf("expected to wait until the window is over, got %v", d)
+	}
+	if d := l.wait("5.6.7.8"); d != 0 {
+		t.Fatalf("other keys are not limited: %v", d)
+	}
+
+	// The window doesn't get longer with more uses.
+	now = now.Add(6 * time.Minute)
+	if d := l.wait("1.2.3.4"); d != 0 {
+		t.Fatalf("limited after the window: %v", d)
+	}
+	l.add("1.2.3.4")
+	if got := l.entries["1.2.3.4"].count; got != 1 {
+		t.Fatalf("expected a new window, got %v uses", got)
+	}
+}
@@ -0,0 +1,209 @@
+package bearblog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelimiter separates the YAML front matter from the content of a
+// Markdown file.
+const frontMatterDelimiter = "---"
+
+// timestampLayouts are the date formats accepted in the front matter of
+// imported files. Jekyll and Hugo both write dates in a few different ways.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// frontMatter is the YAML front matter written at the top of each exported
+// post.
+type frontMatter struct {
+	Title     string    `yaml:"title"`
+	Slug      string    `yaml:"slug"`
+	Canonical string    `yaml:"canonical,omitempty"`
+	Tags      []string  `yaml:"tags"`
+	Page      bool      `yaml:"page"`
+	Published bool      `yaml:"published"`
+	Timestamp time.Time `yaml:"timestamp"`
+}
+
+// importFrontMatter is the YAML front matter read from imported posts. It
+// also accepts the common Jekyll and Hugo fields so those sites can be
+// migrated without editing every file.
+type importFrontMatter struct {
+	Title     string    `yaml:"title"`
+	Slug      string    `yaml:"slug"`
+	Permalink string    `yaml:"permalink"`
+	Canonical string    `yaml:"canonical"`
+	Tags      tagValues `yaml:"tags"`
+	Page      bool      `yaml:"page"`
+	Published *bool     `yaml:"published"`
+	Draft     *bool     `yaml:"draft"`
+	Timestamp string    `yaml:"timestamp"`
+	Date      string    `yaml:"date"`
+}
+
+// tagValues is a list of tags that can be written in YAML as either a list or
+// a comma separated string.
+type tagValues []string
+
+// UnmarshalYAML handles both a list and a comma separated string of tags.
+func (t *tagValues) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = tagValues{}
+		for _, v := range strings.Split(value.Value, ",") {
+			if name := strings.TrimSpace(v); len(name) > 0 {
+				*t = append(*t, name)
+			}
+		}
+		return nil
+	}
+
+	var arr []string
+	if err := value.Decode(&arr); err != nil {
+		return err
+	}
+	*t = arr
+	return nil
+}
+
+// encodeMarkdown returns a post as a Markdown file with YAML front matter.
+func encodeMarkdown(post ambient.Post) ([]byte, error) {
+	fm := frontMatter{
+		Title:     post.Title,
+		Slug:      post.URL,
+		Canonical: post.Canonical,
+		Tags:      make([]string, 0, len(post.Tags)),
+		Page:      post.Page,
+		Published: post.Published,
+		Timestamp: post.Timestamp,
+	}
+	for _, v := range post.Tags {
+		fm.Tags = append(fm.Tags, v.Name)
+	}
+
+	b, err := yaml.Marshal(fm)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.Write(b)
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.WriteString(post.Content)
+
+	return buf.Bytes(), nil
+}
+
+// markdownFileName returns the name of the Markdown file of a post in an
+// export. Only valid slugs are used so the name can't contain a path. Slugs
+// are not guaranteed to be unique so the ID is added if the name is used.
+func markdownFileName(post ambient.PostWithID, used map[string]bool) string {
+	slug := post.URL
+	if !slugPattern.MatchString(slug) {
+		slug = "post"
+	}
+
+	name := slug + ".md"
+	if used[name] {
+		name = slug + "-" + staticQueryUnsafe.ReplaceAllString(post.ID, "_") + ".md"
+	}
+
+	return name
+}
+
+// decodeMarkdown returns a post from a Markdown file with YAML front matter.
+// If the front matter doesn't include a slug, the file name is used instead.
+// Tag timestamps, Created, and Updated are left for the caller to set.
+func decodeMarkdown(filename string, b []byte) (ambient.Post, error) {
+	var post ambient.Post
+
+	s := strings.Replace(string(b), "\r\n", "\n", -1)
+	lines := strings.Split(strings.TrimPrefix(s, "\ufeff"), "\n")
+	if lines[0] != frontMatterDelimiter {
+		return post, errors.New("missing front matter")
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == frontMatterDelimiter {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return post, errors.New("front matter is not closed")
+	}
+
+	header := strings.Join(lines[1:end], "\n")
+	content := strings.Join(lines[end+1:], "\n")
+
+	var fm importFrontMatter
+	err := yaml.Unmarshal([]byte(header), &fm)
+	if err != nil {
+		return post, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	post.Title = fm.Title
+	post.URL = fm.Slug
+	if len(post.URL) == 0 {
+		post.URL = path.Base(strings.Trim(fm.Permalink, "/"))
+	}
+	if len(post.URL) == 0 || post.URL == "." {
+		post.URL = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	}
+	post.Canonical = fm.Canonical
+	post.Content = content
+	post.Page = fm.Page
+
+	post.Published = true
+	if fm.Published != nil {
+		post.Published = *fm.Published
+	} else if fm.Draft != nil {
+		post.Published = !*fm.Draft
+	}
+
+	post.Tags = ambient.TagList{}
+	for _, v := range fm.Tags {
+		post.Tags = append(post.Tags, ambient.Tag{Name: v})
+	}
+
+	date := fm.Timestamp
+	if len(date) == 0 {
+		date = fm.Date
+	}
+	if len(date) > 0 {
+		post.Timestamp, err = parseTimestamp(date)
+		if err != nil {
+			return post, err
+		}
+	}
+
+	return post, nil
+}
+
+// parseTimestamp returns the time from a front matter date. Dates without a
+// time zone are in the local time zone like the dates from the post form.
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date: %v", s)
+}
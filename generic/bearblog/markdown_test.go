@@ -0,0 +1,97 @@
+package bearblog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	post := ambient.Post{
+		Title:     "Hello: world",
+		URL:       "hello-world",
+		Canonical: "https://example.com/hello",
+		Content:   "# Heading\n\n---\n\nBody.",
+		Page:      true,
+		Published: true,
+		Timestamp: time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC),
+		Tags:      ambient.TagList{{Name: "go"}, {Name: "web dev"}},
+	}
+
+	b, err := encodeMarkdown(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decodeMarkdown("ignored.md", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != post.Title || got.URL != post.URL || got.Canonical != post.Canonical ||
+		got.Content != post.Content || got.Page != post.Page || got.Published != post.Published ||
+		!got.Timestamp.Equal(post.Timestamp) || got.Tags.String() != post.Tags.String() {
+		t.Errorf("expected %+v, got %+v", post, got)
+	}
+}
+
+func TestDecodeMarkdownJekyll(t *testing.T) {
+	b := []byte("---\r\ntitle: Old post\r\ndate: 2019-05-01 10:30:00 +0000\r\ntags: a, b\r\ndraft: true\r\n---\r\nText")
+
+	got, err := decodeMarkdown("_posts/2019-05-01-old-post.md", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.URL != "2019-05-01-old-post" {
+		t.Errorf("expected slug from file name, got %v", got.URL)
+	}
+	if got.Published {
+		t.Error("expected draft to not be published")
+	}
+	if got.Tags.String() != "a,b" {
+		t.Errorf("expected tags a,b, got %v", got.Tags.String())
+	}
+	if got.Timestamp.Format("2006-01-02 15:04") != "2019-05-01 10:30" {
+		t.Errorf("unexpected timestamp: %v", got.Timestamp)
+	}
+	if got.Content != "Text" {
+		t.Errorf("unexpected content: %q", got.Content)
+	}
+}
+
+func TestDecodeMarkdownInvalid(t *testing.T) {
+	for _, s := range []string{"no front matter", "---\ntitle: x\n", "---\ntimestamp: yesterday\n---\n"} {
+		if _, err := decodeMarkdown("a.md", []byte(s)); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestMarkdownFileName(t *testing.T) {
+	used := make(map[string]bool)
+
+	tests := []struct {
+		post     ambient.PostWithID
+		expected string
+	}{
+		{ambient.PostWithID{ID: "1", Post: ambient.Post{URL: "hello"}}, "hello.md"},
+		{ambient.PostWithID{ID: "2", Post: ambient.Post{URL: "hello"}}, "hello-2.md"},
+		{ambient.PostWithID{ID: "3", Post: ambient.Post{URL: "../../etc/passwd"}}, "post.md"},
+		{ambient.PostWithID{ID: "../4", Post: ambient.Post{URL: "/abs"}}, "post-.._4.md"},
+		{ambient.PostWithID{ID: "5", Post: ambient.Post{URL: ""}}, "post-5.md"},
+	}
+
+	for _, tt := range tests {
+		got := markdownFileName(tt.post, used)
+		if got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.post.URL, tt.expected, got)
+		}
+		if strings.Contains(got, "/") {
+			t.Errorf("%v: expected no path in %v", tt.post.URL, got)
+		}
+		used[got] = true
+	}
+}
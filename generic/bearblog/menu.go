@@ -0,0 +1,313 @@
+package bearblog
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ambientkit/ambient"
+)
+
+// Kinds of menu items.
+const (
+	menuPage = "page"
+	menuTag  = "tag"
+	menuLink = "link"
+)
+
+// menuBlankRows is the number of empty rows on the menu form for adding
+// items.
+const menuBlankRows = 3
+
+// menuItem is an entry in the header menu. Items can have one level of
+// children that are shown in a submenu.
+type menuItem struct {
+	Kind string `json:"kind"`
+	// Target is the ID of a post, the name of a tag, or a URL.
+	Target   string     `json:"target"`
+	Label    string     `json:"label,omitempty"`
+	NewTab   bool       `json:"newtab,omitempty"`
+	Children []menuItem `json:"children,omitempty"`
+}
+
+// navLink is a menu item that is ready to show in the header.
+type navLink struct {
+	Label    string
+	URL      string
+	NewTab   bool
+	Children []navLink
+}
+
+// menuRow is a menu item on the menu form. Posts are referenced by their
+// permalink instead of their ID.
+type menuRow struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Label  string `json:"label"`
+	NewTab bool   `json:"newtab"`
+	Nested bool   `json:"nested"`
+	Order  int    `json:"order"`
+}
+
+// menu returns the items of the header menu. An empty menu means the menu is
+// made from the published pages.
+func (p *Plugin) menu() ([]menuItem, error) {
+	items := make([]menuItem, 0)
+	err := p.loadData(dataMenu, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// setMenu saves the items of the header menu.
+func (p *Plugin) setMenu(items []menuItem) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	return p.saveData(dataMenu, items)
+}
+
+// navLinks returns the links of the header menu. Items for posts that aren't
+// published are left out. Nothing is returned if the menu is empty.
+func (p *Plugin) navLinks() ([]navLink, error) {
+	items, err := p.menu()
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+
+	posts, err := p.menuPosts()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]ambient.PostWithID)
+	for _, v := range posts {
+		byID[v.ID] = v
+	}
+
+	return resolveMenu(items, byID, p.Path), nil
+}
+
+// menuPosts returns the posts and pages that menu items can link to. Unlisted
+// posts are included since they were added to the menu on purpose.
+func (p *Plugin) menuPosts() (ambient.PostWithIDList, error) {
+	postsAndPages, err := p.postsAndPages(true)
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make(ambient.PostWithIDList, 0, len(postsAndPages))
+	for _, v := range postsAndPages {
+		if !scheduled(v.Post) {
+			arr = append(arr, v)
+		}
+	}
+
+	return arr, nil
+}
+
+// resolveMenu returns the links of the menu items. Items for posts that are
+// not in posts are left out unless they have children. The path function
+// adds the URL prefix to paths on the site.
+func resolveMenu(items []menuItem, posts map[string]ambient.PostWithID, path func(string) string) []navLink {
+	links := make([]navLink, 0, len(items))
+	for _, v := range items {
+		link := navLink{
+			Label:  v.Label,
+			NewTab: v.NewTab,
+		}
+
+		switch v.Kind {
+		case menuPage:
+			if post, ok := posts[v.Target]; ok {
+				link.URL = path("/" + post.URL)
+				if len(link.Label) == 0 {
+					link.Label = post.Title
+				}
+			}
+		case menuTag:
+			link.URL = path(tagURL(v.Target))
+			if len(link.Label) == 0 {
+				link.Label = "#" + v.Target
+			}
+		case menuLink:
+			link.URL = v.Target
+			if strings.HasPrefix(v.Target, "/") {
+				link.URL = path(v.Target)
+			}
+			if len(link.Label) == 0 {
+				link.Label = v.Target
+			}
+		}
+
+		link.Children = resolveMenu(v.Children, posts, path)
+		if len(link.URL) == 0 && len(link.Children) == 0 {
+			continue
+		}
+
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// defaultMenu returns the items of the menu that is shown when no menu is
+// saved.
+func defaultMenu(pages ambient.PostWithIDList) []menuItem {
+	items := []menuItem{{Kind: menuLink, Target: "/", Label: "Home"}}
+	for _, v := range pages {
+		if v.Page {
+			items = append(items, menuItem{Kind: menuPage, Target: v.ID})
+		}
+	}
+
+	return append(items, menuItem{Kind: menuLink, Target: "/blog", Label: "Blog"})
+}
+
+// menuRows returns the rows of the menu form for the items. Items for posts
+// that were deleted are left out and their children are moved up a level.
+func menuRows(items []menuItem, posts map[string]ambient.PostWithID) []menuRow {
+	rows := make([]menuRow, 0)
+	for _, v := range items {
+		_, found := posts[v.Target]
+		parent := v.Kind != menuPage || found
+		if parent {
+			rows = append(rows, newMenuRow(v, false, posts))
+		}
+
+		for _, c := range v.Children {
+			if _, ok := posts[c.Target]; c.Kind != menuPage || ok {
+				rows = append(rows, newMenuRow(c, parent, posts))
+			}
+		}
+	}
+
+	for i := range rows {
+		rows[i].Order = i + 1
+	}
+
+	return rows
+}
+
+// newMenuRow returns the row of the menu form for the item.
+func newMenuRow(item menuItem, nested bool, posts map[string]ambient.PostWithID) menuRow {
+	row := menuRow{
+		Kind:   item.Kind,
+		Target: item.Target,
+		Label:  item.Label,
+		NewTab: item.NewTab,
+		Nested: nested,
+	}
+
+	if post, ok := posts[item.Target]; ok && item.Kind == menuPage {
+		row.Target = post.URL
+	}
+
+	return row
+}
+
+// menuFormRows returns the rows from the menu form sorted by order. Rows
+// without a kind are left out.
+func menuFormRows(form url.Values) []menuRow {
+	count, _ := strconv.Atoi(form.Get("rows"))
+
+	rows := make([]menuRow, 0, count)
+	for i := 0; i < count; i++ {
+		suffix := "_" + strconv.Itoa(i)
+		row := menuRow{
+			Kind:   form.Get("kind" + suffix),
+			Target: strings.TrimSpace(form.Get("target" + suffix)),
+			Label:  strings.TrimSpace(form.Get("label" + suffix)),
+			NewTab: form.Get("newtab"+suffix) == "on",
+			Nested: form.Get("nested"+suffix) == "on",
+		}
+		if len(row.Kind) == 0 {
+			continue
+		}
+
+		row.Order, _ = strconv.Atoi(form.Get("order" + suffix))
+		if row.Order <= 0 {
+			// Rows without an order go to the end in the order of the form.
+			row.Order = count + i + 1
+		}
+
+		rows = append(rows, row)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Order < rows[j].Order
+	})
+
+	return rows
+}
+
+// buildMenu returns the menu items from the rows of the menu form. Nested rows
+// are children of the row above that isn't nested. Slugs maps the permalinks
+// of posts to their ID. A message is returned if the rows can't be used.
+func buildMenu(rows []menuRow, slugs map[string]string) ([]menuItem, string) {
+	items := make([]menuItem, 0)
+	for _, row := range rows {
+		item := menuItem{
+			Kind:   row.Kind,
+			Target: row.Target,
+			Label:  row.Label,
+			NewTab: row.NewTab,
+		}
+
+		switch row.Kind {
+		case menuPage:
+			ID, ok := slugs[strings.Trim(row.Target, "/")]
+			if !ok {
+				return nil, fmt.Sprintf("Page '%v' should be the permalink of a published post or page.", row.Target)
+			}
+			item.Target = ID
+		case menuTag:
+			item.Target = strings.TrimPrefix(row.Target, "#")
+			if len(item.Target) == 0 {
+				return nil, "Tag items need the name of a tag."
+			}
+		case menuLink:
+			if !validMenuURL(row.Target) {
+				return nil, fmt.Sprintf("Link '%v' should start with 'https://', 'mailto:', or '/'.", row.Target)
+			}
+		default:
+			return nil, fmt.Sprintf("Menu item kind '%v' is not supported.", row.Kind)
+		}
+
+		if !row.Nested {
+			items = append(items, item)
+			continue
+		}
+
+		if len(items) == 0 {
+			return nil, "The first menu item can't be nested."
+		}
+		items[len(items)-1].Children = append(items[len(items)-1].Children, item)
+	}
+
+	return items, ""
+}
+
+// validMenuURL returns true if the URL is a full http or https URL, an email
+// link, or a path on the site.
+func validMenuURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || len(s) == 0 {
+		return false
+	}
+
+	switch u.Scheme {
+	case "":
+		return len(u.Host) == 0 && strings.HasPrefix(u.Path, "/")
+	case "http", "https":
+		return len(u.Host) > 0
+	case "mailto":
+		return len(u.Opaque) > 0
+	}
+
+	return false
+}
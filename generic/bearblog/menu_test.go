@@ -0,0 +1,116 @@
+package bearblog
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestBuildMenu(t *testing.T) {
+	form := url.Values{
+		"rows":     {"4"},
+		"order_0":  {"2"},
+		"kind_0":   {"tag"},
+		"target_0": {"#go"},
+		"order_1":  {"1"},
+		"kind_1":   {"link"},
+		"target_1": {"https://example.com"},
+		"newtab_1": {"on"},
+		"kind_2":   {"page"},
+		"target_2": {"/about/"},
+		"nested_2": {"on"},
+		"kind_3":   {""},
+	}
+
+	rows := menuFormRows(form)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %v", rows)
+	}
+
+	items, msg := buildMenu(rows, map[string]string{"about": "id-about"})
+	if len(msg) > 0 {
+		t.Fatal(msg)
+	}
+
+	if len(items) != 2 || items[0].Kind != menuLink || !items[0].NewTab {
+		t.Fatalf("expected the link first, got %+v", items)
+	}
+	if items[1].Target != "go" || len(items[1].Children) != 1 || items[1].Children[0].Target != "id-about" {
+		t.Errorf("expected the page nested under the tag, got %+v", items[1])
+	}
+
+	_, msg = buildMenu([]menuRow{{Kind: menuPage, Target: "missing"}}, nil)
+	if len(msg) == 0 {
+		t.Error("expected a message for a missing page")
+	}
+	_, msg = buildMenu([]menuRow{{Kind: menuTag, Target: "go", Nested: true}}, nil)
+	if len(msg) == 0 {
+		t.Error("expected a message for a nested first item")
+	}
+}
+
+func TestResolveMenu(t *testing.T) {
+	posts := map[string]ambient.PostWithID{
+		"id-about": {ID: "id-about", Post: ambient.Post{Title: "About", URL: "about"}},
+	}
+	path := func(s string) string { return "/prefix" + s }
+
+	links := resolveMenu([]menuItem{
+		{Kind: menuPage, Target: "id-about"},
+		{Kind: menuPage, Target: "id-deleted"},
+		{Kind: menuLink, Target: "https://example.com", Label: "Example", Children: []menuItem{
+			{Kind: menuTag, Target: "go"},
+		}},
+		{Kind: menuPage, Target: "id-deleted", Label: "Group", Children: []menuItem{
+			{Kind: menuLink, Target: "/blog"},
+		}},
+	}, posts, path)
+
+	if len(links) != 3 {
+		t.Fatalf("expected the deleted page to be left out, got %+v", links)
+	}
+	if links[0].URL != "/prefix/about" || links[0].Label != "About" {
+		t.Errorf("expected the page URL and title, got %+v", links[0])
+	}
+	if links[1].URL != "https://example.com" || links[1].Children[0].URL != "/prefix/blog/tag/go" || links[1].Children[0].Label != "#go" {
+		t.Errorf("unexpected links: %+v", links[1])
+	}
+	if links[2].URL != "" || links[2].Children[0].URL != "/prefix/blog" {
+		t.Errorf("expected a group without a URL, got %+v", links[2])
+	}
+}
+
+func TestMenuRows(t *testing.T) {
+	posts := map[string]ambient.PostWithID{
+		"id-about": {ID: "id-about", Post: ambient.Post{Title: "About", URL: "about"}},
+	}
+
+	rows := menuRows([]menuItem{
+		{Kind: menuPage, Target: "id-deleted", Children: []menuItem{
+			{Kind: menuPage, Target: "id-about"},
+		}},
+	}, posts)
+
+	if len(rows) != 1 || rows[0].Target != "about" || rows[0].Nested || rows[0].Order != 1 {
+		t.Errorf("expected the child to move up a level, got %+v", rows)
+	}
+}
+
+func TestValidMenuURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com":   true,
+		"/blog":                 true,
+		"mailto:me@example.com": true,
+		"":                      false,
+		"//example.com":         false,
+		"javascript:alert(1)":   false,
+		"blog":                  false,
+	}
+
+	for in, expected := range tests {
+		if got := validMenuURL(in); got != expected {
+			t.Errorf("%v: expected %v, got %v", in, expected, got)
+		}
+	}
+}
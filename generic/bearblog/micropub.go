@@ -0,0 +1,347 @@
+package bearblog
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+// Micropub actions.
+const (
+	micropubCreate = "create"
+	micropubUpdate = "update"
+	micropubDelete = "delete"
+)
+
+// micropubProperties are the microformats2 properties of an h-entry. Every
+// property is a list of values.
+type micropubProperties map[string][]interface{}
+
+// micropubRequest is a create, update, or delete request from a Micropub
+// client.
+type micropubRequest struct {
+	Action     string
+	URL        string
+	Properties micropubProperties
+	Replace    micropubProperties
+	Add        micropubProperties
+	// Delete removes entire properties by name or only some of their values.
+	DeleteNames  []string
+	DeleteValues micropubProperties
+}
+
+// micropubError is the error format from the Micropub specification.
+type micropubError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// micropubTokens returns the tokens that Micropub clients can use.
+func (p *Plugin) micropubTokens() ([]string, error) {
+	s, err := p.Site.PluginSettingString(MicropubTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitMicropubTokens(s), nil
+}
+
+// splitMicropubTokens returns the tokens in a list separated by commas.
+// Tokens on separate lines from older versions are also split.
+func splitMicropubTokens(s string) []string {
+	tokens := make([]string, 0)
+	for _, v := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			tokens = append(tokens, v)
+		}
+	}
+
+	return tokens
+}
+
+// micropubToken returns the access token from the Authorization header or
+// the access_token form field.
+func micropubToken(r *http.Request) string {
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		return strings.TrimSpace(bearer[7:])
+	}
+
+	return r.FormValue("access_token")
+}
+
+// validMicropubToken returns true if the token is one of the allowed tokens.
+func validMicropubToken(token string, allowed []string) bool {
+	if len(token) == 0 {
+		return false
+	}
+
+	valid := false
+	for _, v := range allowed {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(v)) == 1 {
+			valid = true
+		}
+	}
+
+	return valid
+}
+
+// parseMicropubForm returns the request from a form-encoded body. Fields
+// that end in [] are lists.
+func parseMicropubForm(form url.Values) (micropubRequest, error) {
+	req := micropubRequest{
+		Action:     form.Get("action"),
+		URL:        form.Get("url"),
+		Properties: make(micropubProperties),
+	}
+
+	if len(req.Action) == 0 {
+		req.Action = micropubCreate
+		if h := form.Get("h"); h != "entry" {
+			return req, fmt.Errorf("only h-entry posts are supported, not '%v'", h)
+		}
+	}
+
+	for k, values := range form {
+		k = strings.TrimSuffix(k, "[]")
+		switch k {
+		case "h", "action", "url", "access_token":
+			continue
+		}
+
+		for _, v := range values {
+			req.Properties[k] = append(req.Properties[k], v)
+		}
+	}
+
+	return req, nil
+}
+
+// parseMicropubJSON returns the request from a JSON body.
+func parseMicropubJSON(body []byte) (micropubRequest, error) {
+	var raw struct {
+		Type       []string           `json:"type"`
+		Action     string             `json:"action"`
+		URL        string             `json:"url"`
+		Properties micropubProperties `json:"properties"`
+		Replace    micropubProperties `json:"replace"`
+		Add        micropubProperties `json:"add"`
+		Delete     json.RawMessage    `json:"delete"`
+	}
+
+	req := micropubRequest{}
+	err := json.Unmarshal(body, &raw)
+	if err != nil {
+		return req, fmt.Errorf("request body is not valid JSON: %v", err.Error())
+	}
+
+	req.Action = raw.Action
+	req.URL = raw.URL
+	req.Properties = raw.Properties
+	req.Replace = raw.Replace
+	req.Add = raw.Add
+
+	if len(req.Action) == 0 {
+		req.Action = micropubCreate
+		if len(raw.Type) != 1 || raw.Type[0] != "h-entry" {
+			return req, fmt.Errorf("only h-entry posts are supported, not %v", raw.Type)
+		}
+	}
+
+	if len(raw.Delete) > 0 {
+		if err := json.Unmarshal(raw.Delete, &req.DeleteNames); err != nil {
+			if err := json.Unmarshal(raw.Delete, &req.DeleteValues); err != nil {
+				return req, fmt.Errorf("delete must be a list of properties or an object of values")
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// micropubString returns the value as a string. The content property can be
+// an object with the HTML or the plain text value.
+func micropubString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case map[string]interface{}:
+		if s, ok := t["html"].(string); ok {
+			return s
+		} else if s, ok := t["value"].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// micropubFirst returns the first value of a property as a string.
+func micropubFirst(props micropubProperties, name string) string {
+	if len(props[name]) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(micropubString(props[name][0]))
+}
+
+// micropubTags returns the categories of an entry as tags.
+func micropubTags(values []interface{}, now time.Time) ambient.TagList {
+	tags := make(ambient.TagList, 0, len(values))
+	for _, v := range values {
+		name := strings.TrimSpace(micropubString(v))
+		if len(name) == 0 || hasTag(tags, name) {
+			continue
+		}
+		tags = append(tags, ambient.Tag{Name: name, Timestamp: now})
+	}
+
+	return tags
+}
+
+// setMicropubProperties sets the fields of the post from the properties.
+// Properties that aren't supported are ignored.
+func setMicropubProperties(post *ambient.Post, props micropubProperties, now time.Time) error {
+	for name, values := range props {
+		if len(values) == 0 {
+			continue
+		}
+
+		switch name {
+		case "name":
+			post.Title = micropubFirst(props, name)
+		case "content":
+			post.Content = micropubFirst(props, name)
+		case "category":
+			post.Tags = micropubTags(values, now)
+		case "mp-slug":
+			post.URL = micropubFirst(props, name)
+		case "post-status":
+			switch status := micropubFirst(props, name); status {
+			case "published":
+				post.Published = true
+			case "draft":
+				post.Published = false
+			default:
+				return fmt.Errorf("post-status '%v' is not supported", status)
+			}
+		case "published":
+			t, err := time.Parse(time.RFC3339, micropubFirst(props, name))
+			if err != nil {
+				return fmt.Errorf("published must be an RFC3339 date: %v", err.Error())
+			}
+			post.Timestamp = t
+		}
+	}
+
+	return nil
+}
+
+// addMicropubProperties adds values to the properties of the post. Only
+// categories can have more than one value so the rest are replaced.
+func addMicropubProperties(post *ambient.Post, props micropubProperties, now time.Time) error {
+	others := make(micropubProperties)
+	for name, values := range props {
+		if name != "category" {
+			others[name] = values
+			continue
+		}
+
+		for _, v := range micropubTags(values, now) {
+			if !hasTag(post.Tags, v.Name) {
+				post.Tags = append(post.Tags, v)
+			}
+		}
+	}
+
+	return setMicropubProperties(post, others, now)
+}
+
+// deleteMicropubProperties removes entire properties by name or only some of
+// their values.
+func deleteMicropubProperties(post *ambient.Post, names []string, values micropubProperties) {
+	for _, name := range names {
+		switch name {
+		case "name":
+			post.Title = ""
+		case "content":
+			post.Content = ""
+		case "category":
+			post.Tags = make(ambient.TagList, 0)
+		}
+	}
+
+	remove := micropubTags(values["category"], time.Time{})
+	tags := make(ambient.TagList, 0, len(post.Tags))
+	for _, v := range post.Tags {
+		if !hasTag(remove, v.Name) {
+			tags = append(tags, v)
+		}
+	}
+	post.Tags = tags
+}
+
+// micropubCategories returns the names of tags as property values.
+func micropubCategories(tags ambient.TagList) []interface{} {
+	values := make([]interface{}, 0, len(tags))
+	for _, v := range tags {
+		values = append(values, v.Name)
+	}
+
+	return values
+}
+
+// micropubSource returns the properties of a post for a q=source query. If
+// names is not empty, only those properties are returned.
+func micropubSource(post ambient.Post, postURL string, names []string) micropubProperties {
+	status := "draft"
+	if post.Published {
+		status = "published"
+	}
+
+	props := micropubProperties{
+		"name":        {post.Title},
+		"content":     {post.Content},
+		"category":    micropubCategories(post.Tags),
+		"mp-slug":     {post.URL},
+		"post-status": {status},
+		"published":   {post.Timestamp.Format(time.RFC3339)},
+		"url":         {postURL},
+	}
+
+	if len(names) == 0 {
+		return props
+	}
+
+	filtered := make(micropubProperties)
+	for _, name := range names {
+		if v, ok := props[name]; ok {
+			filtered[name] = v
+		}
+	}
+
+	return filtered
+}
+
+// micropubSlug returns the slug of a post from its full URL.
+func micropubSlug(postURL string) string {
+	u, err := url.Parse(postURL)
+	if err != nil {
+		return ""
+	}
+
+	slug := strings.Trim(u.Path, "/")
+	if len(slug) == 0 {
+		return ""
+	}
+
+	return path.Base(slug)
+}
@@ -0,0 +1,165 @@
+package bearblog
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestParseMicropubForm(t *testing.T) {
+	form := url.Values{
+		"h":            {"entry"},
+		"name":         {"Hello"},
+		"category[]":   {"go", "web"},
+		"access_token": {"secret"},
+	}
+
+	req, err := parseMicropubForm(form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Action != micropubCreate {
+		t.Errorf("expected action %v, got %v", micropubCreate, req.Action)
+	}
+	if len(req.Properties["category"]) != 2 {
+		t.Errorf("expected 2 categories, got %v", req.Properties["category"])
+	}
+	if _, ok := req.Properties["access_token"]; ok {
+		t.Error("expected the access token to not be a property")
+	}
+
+	_, err = parseMicropubForm(url.Values{"h": {"event"}})
+	if err == nil {
+		t.Error("expected an error for an h-event")
+	}
+
+	req, err = parseMicropubForm(url.Values{"action": {"delete"}, "url": {"https://example.com/hello"}})
+	if err != nil || req.Action != micropubDelete || req.URL != "https://example.com/hello" {
+		t.Errorf("expected a delete request, got %v (%v)", req, err)
+	}
+}
+
+func TestParseMicropubJSON(t *testing.T) {
+	req, err := parseMicropubJSON([]byte(`{"type":["h-entry"],"properties":{"content":[{"html":"<p>Hi</p>"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := micropubFirst(req.Properties, "content"); got != "<p>Hi</p>" {
+		t.Errorf("expected HTML content, got %v", got)
+	}
+
+	req, err = parseMicropubJSON([]byte(`{"action":"update","url":"u","delete":["category"]}`))
+	if err != nil || len(req.DeleteNames) != 1 || req.DeleteNames[0] != "category" {
+		t.Errorf("expected delete names, got %v (%v)", req.DeleteNames, err)
+	}
+
+	req, err = parseMicropubJSON([]byte(`{"action":"update","url":"u","delete":{"category":["go"]}}`))
+	if err != nil || len(req.DeleteValues["category"]) != 1 {
+		t.Errorf("expected delete values, got %v (%v)", req.DeleteValues, err)
+	}
+
+	_, err = parseMicropubJSON([]byte(`{"type":["h-card"]}`))
+	if err == nil {
+		t.Error("expected an error for an h-card")
+	}
+}
+
+func TestMicropubProperties(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	post := ambient.Post{Published: true}
+
+	err := setMicropubProperties(&post, micropubProperties{
+		"name":        {"Hello"},
+		"content":     {"Body"},
+		"category":    {"go", "go", "web"},
+		"mp-slug":     {"hello-world"},
+		"post-status": {"draft"},
+		"published":   {"2020-06-01T10:00:00Z"},
+		"photo":       {"https://example.com/a.jpg"},
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.Title != "Hello" || post.Content != "Body" || post.URL != "hello-world" || post.Published {
+		t.Errorf("unexpected post: %+v", post)
+	}
+	if len(post.Tags) != 2 {
+		t.Errorf("expected duplicate categories to be removed, got %v", post.Tags)
+	}
+	if post.Timestamp.Year() != 2020 {
+		t.Errorf("expected the published date to be used, got %v", post.Timestamp)
+	}
+
+	err = addMicropubProperties(&post, micropubProperties{"category": {"web", "api"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteMicropubProperties(&post, []string{"content"}, micropubProperties{"category": {"go"}})
+
+	if post.Content != "" {
+		t.Errorf("expected content to be deleted, got %v", post.Content)
+	}
+	if len(post.Tags) != 2 || post.Tags[0].Name != "web" || post.Tags[1].Name != "api" {
+		t.Errorf("expected tags web and api, got %v", post.Tags)
+	}
+
+	err = setMicropubProperties(&post, micropubProperties{"post-status": {"private"}}, now)
+	if err == nil {
+		t.Error("expected an error for an unknown post status")
+	}
+}
+
+func TestValidMicropubToken(t *testing.T) {
+	allowed := []string{"one", "two"}
+
+	tests := []struct {
+		token    string
+		expected bool
+	}{
+		{"one", true},
+		{"two", true},
+		{"three", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := validMicropubToken(tt.token, allowed); got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.token, tt.expected, got)
+		}
+	}
+}
+
+func TestSplitMicropubTokens(t *testing.T) {
+	tests := map[string]int{
+		"":               0,
+		"one":            1,
+		"one, two,,":     2,
+		"one\n two \n":   2,
+		"one,two\nthree": 3,
+	}
+
+	for in, expected := range tests {
+		if got := splitMicropubTokens(in); len(got) != expected {
+			t.Errorf("%q: expected %v tokens, got %v", in, expected, got)
+		}
+	}
+}
+
+func TestMicropubSlug(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/hello-world":  "hello-world",
+		"https://example.com/hello-world/": "hello-world",
+		"/blog/hello":                      "hello",
+		"https://example.com/":             "",
+	}
+
+	for in, expected := range tests {
+		if got := micropubSlug(in); got != expected {
+			t.Errorf("%v: expected %v, got %v", in, expected, got)
+		}
+	}
+}
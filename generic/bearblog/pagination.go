@@ -0,0 +1,83 @@
+package bearblog
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// defaultPageSize is used when the page size setting is missing or invalid.
+const defaultPageSize = 20
+
+// pageSize returns the number of posts to show on each page of a list.
+func (p *Plugin) pageSize() int {
+	s, err := p.Site.PluginSettingString(PageSize)
+	if err != nil {
+		p.Log.Warn("bearblog: error getting page size: %v", err.Error())
+		return defaultPageSize
+	}
+
+	size, err := strconv.Atoi(s)
+	if err != nil || size < 1 {
+		return defaultPageSize
+	}
+
+	return size
+}
+
+// paginate sets the pagination variables for a list with total items and
+// returns the bounds of the items on the requested page. The basePath is the
+// path of the list without the URL prefix. A status error is returned if the
+// page does not exist.
+func (p *Plugin) paginate(r *http.Request, vars map[string]interface{}, basePath string, total int) (start int, end int, err error) {
+	size := p.pageSize()
+
+	pages := (total + size - 1) / size
+	if pages < 1 {
+		pages = 1
+	}
+
+	page := 1
+	if s := r.URL.Query().Get("page"); len(s) > 0 {
+		page, err = strconv.Atoi(s)
+		if err != nil || page < 1 || page > pages {
+			return 0, 0, p.Mux.StatusError(http.StatusNotFound, nil)
+		}
+	}
+
+	pageURL := func(n int) string {
+		q := r.URL.Query()
+		if n == 1 {
+			q.Del("page")
+		} else {
+			q.Set("page", strconv.Itoa(n))
+		}
+
+		u := p.Path(basePath)
+		if encoded := q.Encode(); len(encoded) > 0 {
+			u += "?" + encoded
+		}
+		return u
+	}
+
+	vars["page"] = page
+	vars["pages"] = pages
+	vars["prevurl"] = ""
+	vars["nexturl"] = ""
+	if page > 1 {
+		vars["prevurl"] = pageURL(page - 1)
+	}
+	if page < pages {
+		vars["nexturl"] = pageURL(page + 1)
+	}
+
+	start = (page - 1) * size
+	if start > total {
+		start = total
+	}
+	end = start + size
+	if end > total {
+		end = total
+	}
+
+	return start, end, nil
+}
@@ -0,0 +1,230 @@
+package bearblog
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/generic/sitemap"
+)
+
+// scheduled returns true if the post has a publish date in the future.
+func scheduled(post ambient.Post) bool {
+	return post.Timestamp.After(time.Now())
+}
+
+// publishDate returns the publish date from the post form. The date is in the
+// same location as now so today isn't treated as a later date. Today is used
+// if the date is empty.
+func publishDate(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		s = now.Format("2006-01-02")
+	}
+
+	return time.ParseInLocation("2006-01-02", s, now.Location())
+}
+
+// livePostsAndPages returns the published posts and pages that are not
+// scheduled for a later date or unlisted.
+func (p *Plugin) livePostsAndPages() (ambient.PostWithIDList, error) {
+	postsAndPages, err := p.postsAndPages(true)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := p.postAccesses()
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make(ambient.PostWithIDList, 0)
+	for _, v := range postsAndPages {
+		if !scheduled(v.Post) && !accesses[v.ID].Unlisted {
+			arr = append(arr, v)
+		}
+	}
+
+	return arr, nil
+}
+
+// livePosts returns the published posts that are not scheduled for a later
+// date or unlisted.
+func (p *Plugin) livePosts() ([]ambient.Post, error) {
+	return p.liveWhere(false)
+}
+
+// livePages returns the published pages that are not scheduled for a later
+// date or unlisted.
+func (p *Plugin) livePages() ([]ambient.Post, error) {
+	return p.liveWhere(true)
+}
+
+// liveWhere returns either the live pages or the live posts.
+func (p *Plugin) liveWhere(pages bool) ([]ambient.Post, error) {
+	postsAndPages, err := p.livePostsAndPages()
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make([]ambient.Post, 0)
+	for _, v := range postsAndPages {
+		if v.Page == pages {
+			arr = append(arr, v.Post)
+		}
+	}
+
+	return arr, nil
+}
+
+// liveTags returns the tags used by the posts sorted by name.
+func liveTags(posts ambient.PostWithIDList) ambient.TagList {
+	m := make(map[string]ambient.Tag)
+	for _, v := range posts {
+		for _, t := range v.Tags {
+			m[t.Name] = t
+		}
+	}
+
+	arr := make(ambient.TagList, 0, len(m))
+	for _, v := range m {
+		arr = append(arr, v)
+	}
+	sort.Sort(arr)
+
+	return arr
+}
+
+// archivePages returns the archive pages of the tags and months of the posts
+// for the sitemap. Each page was last modified when its newest post was
+// published. Pages are only listed in the archives of their tags.
+func archivePages(posts ambient.PostWithIDList) []sitemap.Page {
+	modified := make(map[string]time.Time)
+	latest := func(path string, t time.Time) {
+		if t.After(modified[path]) {
+			modified[path] = t
+		}
+	}
+
+	for _, v := range posts {
+		for _, t := range v.Tags {
+			latest(tagURL(t.Name), v.Timestamp)
+		}
+		if !v.Page {
+			latest(monthURL(v.Timestamp.Year(), int(v.Timestamp.Month())), v.Timestamp)
+		}
+	}
+
+	arr := make([]sitemap.Page, 0, len(modified))
+	for path, t := range modified {
+		arr = append(arr, sitemap.Page{Path: path, LastModified: t})
+	}
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Path < arr[j].Path
+	})
+
+	return arr
+}
+
+// nextScheduled returns the earliest publish date of the published posts that
+// are scheduled for a later date or a zero time if there are none.
+func nextScheduled(posts ambient.PostWithIDList) time.Time {
+	var next time.Time
+	for _, v := range posts {
+		if scheduled(v.Post) && (next.IsZero() || v.Timestamp.Before(next)) {
+			next = v.Timestamp
+		}
+	}
+
+	return next
+}
+
+// postsAndPages returns the posts and pages with hidden posts marked as
+// published if they are.
+func (p *Plugin) postsAndPages(onlyPublished bool) (ambient.PostWithIDList, error) {
+	postsAndPages, err := p.Site.PostsAndPages(false)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := p.postAccesses()
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make(ambient.PostWithIDList, 0, len(postsAndPages))
+	for _, v := range postsAndPages {
+		v.Post = restorePublished(v.Post, accesses[v.ID])
+		if !onlyPublished || v.Published {
+			arr = append(arr, v)
+		}
+	}
+
+	return arr, nil
+}
+
+// postBySlug returns a post with a hidden post marked as published if it is.
+func (p *Plugin) postBySlug(slug string) (ambient.PostWithID, error) {
+	post, err := p.Site.PostBySlug(slug)
+	if err != nil || len(post.ID) == 0 {
+		return post, err
+	}
+
+	access, err := p.postAccess(post.ID)
+	if err != nil {
+		return post, err
+	}
+	post.Post = restorePublished(post.Post, access)
+
+	return post, nil
+}
+
+// postByID returns a post with a hidden post marked as published if it is.
+func (p *Plugin) postByID(ID string) (ambient.Post, error) {
+	post, err := p.Site.PostByID(ID)
+	if err != nil {
+		return post, err
+	}
+
+	access, err := p.postAccess(ID)
+	if err != nil {
+		return post, err
+	}
+
+	return restorePublished(post, access), nil
+}
+
+// savePost saves a post to storage and refreshes the data derived from posts.
+// Hidden posts are saved as drafts.
+func (p *Plugin) savePost(ID string, post ambient.Post) error {
+	post, err := p.hidePost(ID, post)
+	if err != nil {
+		return err
+	}
+
+	err = p.Site.SavePost(ID, post)
+	if err != nil {
+		return err
+	}
+
+	p.postsChanged()
+	return nil
+}
+
+// deletePost deletes a post from storage and refreshes the data derived from
+// posts.
+func (p *Plugin) deletePost(ID string) error {
+	err := p.Site.DeletePostByID(ID)
+	if err != nil {
+		return err
+	}
+
+	p.postsChanged()
+	return nil
+}
+
+// postsChanged should be called any time posts are added, updated, or removed
+// so cached data is rebuilt.
+func (p *Plugin) postsChanged() {
+	p.searchIndex.Invalidate()
+	p.relatedIndex.Invalidate()
+}
@@ -0,0 +1,66 @@
+package bearblog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestPublishDate(t *testing.T) {
+	// Early in the morning ten hours ahead of UTC, it's still the day before
+	// in UTC.
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	now := time.Date(2021, 1, 1, 5, 0, 0, 0, loc)
+
+	ts, err := publishDate("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.After(now) {
+		t.Fatalf("expected today to not be scheduled, got %v", ts)
+	}
+	if ts.Format("2006-01-02") != "2021-01-01" || ts.Location() != loc {
+		t.Fatalf("expected 2021-01-01 in %v, got %v", loc, ts)
+	}
+
+	ts, err = publishDate("2021-01-02", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ts.After(now) {
+		t.Fatalf("expected tomorrow to be scheduled, got %v", ts)
+	}
+
+	_, err = publishDate("tomorrow", now)
+	if err == nil {
+		t.Fatal("expected an error for an invalid date")
+	}
+}
+
+func TestArchivePages(t *testing.T) {
+	jan := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	later := time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC)
+	posts := ambient.PostWithIDList{
+		{ID: "1", Post: ambient.Post{Timestamp: jan, Tags: ambient.TagList{{Name: "go"}}}},
+		{ID: "2", Post: ambient.Post{Timestamp: later, Tags: ambient.TagList{{Name: "go"}, {Name: "web"}}}},
+		{ID: "3", Post: ambient.Post{Timestamp: time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), Page: true, Tags: ambient.TagList{{Name: "about"}}}},
+	}
+
+	expected := map[string]time.Time{
+		tagURL("about"): time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC),
+		tagURL("go"):    later,
+		tagURL("web"):   later,
+		"/blog/2021/01": later,
+	}
+
+	pages := archivePages(posts)
+	if len(pages) != len(expected) {
+		t.Fatalf("expected %v pages, got %v", len(expected), pages)
+	}
+	for _, v := range pages {
+		if modified, ok := expected[v.Path]; !ok || !v.LastModified.Equal(modified) {
+			t.Errorf("%v: expected %v, got %v", v.Path, modified, v.LastModified)
+		}
+	}
+}
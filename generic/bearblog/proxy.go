@@ -0,0 +1,109 @@
+package bearblog
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientIP returns the IP address of the client without the port. The
+// X-Forwarded-For header is only used for requests from the trusted proxies
+// in the settings since any client can set it. Without trusted proxies, every
+// request behind a reverse proxy has the IP address of the proxy so a lockout
+// of one client locks out all of them.
+func (p *Plugin) clientIP(r *http.Request) string {
+	s, err := p.Site.PluginSettingString(TrustedProxies)
+	if err != nil {
+		p.Log.Warn("bearblog: error getting trusted proxies: %v", err.Error())
+	}
+
+	return forwardedIP(r, parseTrustedProxies(s))
+}
+
+// parseTrustedProxies returns the networks of a comma separated list of IP
+// addresses and CIDR ranges. Invalid entries are skipped.
+func parseTrustedProxies(s string) []*net.IPNet {
+	arr := make([]*net.IPNet, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			arr = append(arr, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err == nil {
+			arr = append(arr, n)
+		}
+	}
+
+	return arr
+}
+
+// forwardedIP returns the IP address of the client. If the request is from a
+// trusted proxy, the X-Forwarded-For header is read from right to left and
+// the first address that isn't a trusted proxy is returned.
+func forwardedIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !trustedIP(ip, trusted) {
+		return ip
+	}
+
+	hops := make([]string, 0)
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		ip = hop
+		if !trustedIP(ip, trusted) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// remoteIP returns the IP address of the connection without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// trustedIP returns true if the IP address is in one of the networks.
+func trustedIP(s string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
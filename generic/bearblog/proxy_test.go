@@ -0,0 +1,48 @@
+package bearblog
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedIP(t *testing.T) {
+	trusted := parseTrustedProxies("10.0.0.1, 192.168.0.0/16, bad, ::1")
+
+	tests := []struct {
+		remote    string
+		forwarded string
+		expected  string
+	}{
+		{"203.0.113.9:1234", "", "203.0.113.9"},
+		{"203.0.113.9:1234", "198.51.100.1", "203.0.113.9"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"10.0.0.1:1234", "1.1.1.1, 198.51.100.1", "198.51.100.1"},
+		{"10.0.0.1:1234", "198.51.100.1, 192.168.1.5", "198.51.100.1"},
+		{"10.0.0.1:1234", "192.168.1.5", "192.168.1.5"},
+		{"10.0.0.1:1234", "1.1.1.1, junk", "10.0.0.1"},
+		{"[::1]:1234", "2001:db8::1", "2001:db8::1"},
+		{"10.0.0.2:1234", "198.51.100.1", "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if len(tt.forwarded) > 0 {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+
+		if got := forwardedIP(r, trusted); got != tt.expected {
+			t.Errorf("%v %v: expected %v, got %v", tt.remote, tt.forwarded, tt.expected, got)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if got := len(parseTrustedProxies("")); got != 0 {
+		t.Fatalf("empty: expected 0, got %v", got)
+	}
+	if got := len(parseTrustedProxies("10.0.0.1, 10.1.0.0/16, nope, 10.2.0.0/99")); got != 2 {
+		t.Fatalf("expected 2, got %v", got)
+	}
+}
@@ -0,0 +1,218 @@
+package bearblog
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+const (
+	// relatedPostCount is the number of related posts shown on a post.
+	relatedPostCount = 3
+	// weightSharedTags is how much the share of tags in common counts
+	// compared to the content similarity, which is between 0 and 1.
+	weightSharedTags = 1.0
+	// minTermLength is the shortest word used to compare content so short
+	// words like "a" and "of" are ignored.
+	minTermLength = 3
+)
+
+// relatedIndex is an in-memory cache of the related posts for each published
+// post.
+type relatedIndex struct {
+	mu      sync.RWMutex
+	valid   bool
+	expires time.Time
+	related map[string][]ambient.PostWithID
+}
+
+// newRelatedIndex returns an empty related index that will be built on first
+// use.
+func newRelatedIndex() *relatedIndex {
+	return &relatedIndex{}
+}
+
+// Invalidate marks the index as stale so it is rebuilt on the next lookup.
+func (ri *relatedIndex) Invalidate() {
+	ri.mu.Lock()
+	ri.valid = false
+	ri.mu.Unlock()
+}
+
+// Stale returns true if the index needs to be rebuilt.
+func (ri *relatedIndex) Stale() bool {
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+
+	return !ri.valid || (!ri.expires.IsZero() && !time.Now().Before(ri.expires))
+}
+
+// Build replaces the contents of the index with the related posts for each
+// of the posts. If expires is not zero, the index becomes stale at that time.
+func (ri *relatedIndex) Build(posts []ambient.PostWithID, expires time.Time) {
+	vectors := tfidfVectors(posts)
+
+	related := make(map[string][]ambient.PostWithID, len(posts))
+	for i, a := range posts {
+		type scored struct {
+			post  ambient.PostWithID
+			score float64
+		}
+
+		arr := make([]scored, 0)
+		for j, b := range posts {
+			if i == j {
+				continue
+			}
+
+			score := weightSharedTags*sharedTags(a.Tags, b.Tags) + cosineSimilarity(vectors[i], vectors[j])
+			if score > 0 {
+				arr = append(arr, scored{post: b, score: score})
+			}
+		}
+
+		sort.Slice(arr, func(i, j int) bool {
+			if arr[i].score != arr[j].score {
+				return arr[i].score > arr[j].score
+			}
+			return arr[i].post.Timestamp.After(arr[j].post.Timestamp)
+		})
+
+		if len(arr) > relatedPostCount {
+			arr = arr[:relatedPostCount]
+		}
+
+		list := make([]ambient.PostWithID, 0, len(arr))
+		for _, v := range arr {
+			list = append(list, v.post)
+		}
+		related[a.ID] = list
+	}
+
+	ri.mu.Lock()
+	ri.related = related
+	ri.expires = expires
+	ri.valid = true
+	ri.mu.Unlock()
+}
+
+// Related returns the related posts for the post with the ID.
+func (ri *relatedIndex) Related(ID string) []ambient.PostWithID {
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+
+	return ri.related[ID]
+}
+
+// relatedPosts returns the published posts that are most like the post with
+// the ID. The index is rebuilt first if the posts have changed or a scheduled
+// post has been released.
+func (p *Plugin) relatedPosts(ID string) ([]ambient.PostWithID, error) {
+	if p.relatedIndex.Stale() {
+		postsAndPages, err := p.postsAndPages(true)
+		if err != nil {
+			return nil, err
+		}
+
+		accesses, err := p.postAccesses()
+		if err != nil {
+			return nil, err
+		}
+
+		live := make([]ambient.PostWithID, 0)
+		for _, v := range postsAndPages {
+			if !v.Page && !scheduled(v.Post) && !accesses[v.ID].Unlisted {
+				live = append(live, v)
+			}
+		}
+
+		p.relatedIndex.Build(live, nextScheduled(postsAndPages))
+	}
+
+	return p.relatedIndex.Related(ID), nil
+}
+
+// tfidfVectors returns the TF-IDF weight of each word in the title and
+// content of each post. The vectors are normalized so the cosine similarity is
+// the dot product.
+func tfidfVectors(posts []ambient.PostWithID) []map[string]float64 {
+	counts := make([]map[string]float64, len(posts))
+	docFreq := make(map[string]int)
+	for i, v := range posts {
+		tf := make(map[string]float64)
+		for _, term := range tokenize(v.Title + " " + plaintext(v.Content)) {
+			if len([]rune(term)) >= minTermLength {
+				tf[term]++
+			}
+		}
+
+		for term := range tf {
+			docFreq[term]++
+		}
+		counts[i] = tf
+	}
+
+	n := float64(len(posts))
+	for _, tf := range counts {
+		var norm float64
+		for term, count := range tf {
+			// Words in every post have no weight.
+			w := count * math.Log(n/float64(docFreq[term]))
+			tf[term] = w
+			norm += w * w
+		}
+
+		norm = math.Sqrt(norm)
+		for term, w := range tf {
+			if norm == 0 {
+				delete(tf, term)
+				continue
+			}
+			tf[term] = w / norm
+		}
+	}
+
+	return counts
+}
+
+// cosineSimilarity returns the dot product of two normalized vectors.
+func cosineSimilarity(a map[string]float64, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	var sum float64
+	for term, w := range a {
+		sum += w * b[term]
+	}
+
+	return sum
+}
+
+// sharedTags returns the number of tags in common divided by the number of
+// different tags on both posts.
+func sharedTags(a ambient.TagList, b ambient.TagList) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	names := make(map[string]bool)
+	for _, t := range a {
+		names[t.Name] = true
+	}
+
+	common := 0
+	all := len(names)
+	for _, t := range b {
+		if names[t.Name] {
+			common++
+		} else {
+			all++
+		}
+	}
+
+	return float64(common) / float64(all)
+}
@@ -0,0 +1,77 @@
+package bearblog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestRelatedIndex(t *testing.T) {
+	now := time.Now()
+	post := func(ID string, content string, tags ...string) ambient.PostWithID {
+		tl := make(ambient.TagList, 0)
+		for _, v := range tags {
+			tl = append(tl, ambient.Tag{Name: v})
+		}
+		return ambient.PostWithID{
+			ID: ID,
+			Post: ambient.Post{
+				Title:     "Post " + ID,
+				Content:   content,
+				Timestamp: now,
+				Tags:      tl,
+			},
+		}
+	}
+
+	ri := newRelatedIndex()
+	if !ri.Stale() {
+		t.Fatal("expected new index to be stale")
+	}
+
+	ri.Build([]ambient.PostWithID{
+		post("1", "Writing *templates* in Go with the html/template package.", "go"),
+		post("2", "More about Go templates and template functions."),
+		post("3", "Baking sourdough bread at home.", "go"),
+		post("4", "Gardening in spring."),
+		post("5", "Another recipe for bread, baked slowly."),
+	}, time.Time{})
+
+	related := ri.Related("1")
+	if len(related) != 2 {
+		t.Fatalf("expected 2 related posts, got %v", len(related))
+	}
+	if related[0].ID != "3" || related[1].ID != "2" {
+		t.Errorf("expected shared tag to rank first, got %v then %v", related[0].ID, related[1].ID)
+	}
+
+	related = ri.Related("5")
+	if len(related) != 1 || related[0].ID != "3" {
+		t.Errorf("expected only post 3, got %v", related)
+	}
+
+	if related := ri.Related("4"); len(related) != 0 {
+		t.Errorf("expected no related posts, got %v", related)
+	}
+
+	ri.Invalidate()
+	if !ri.Stale() {
+		t.Error("expected index to be stale after invalidate")
+	}
+}
+
+func TestSharedTags(t *testing.T) {
+	a := ambient.TagList{{Name: "go"}, {Name: "web"}}
+	b := ambient.TagList{{Name: "go"}, {Name: "cli"}}
+
+	if got := sharedTags(a, b); got != 1.0/3 {
+		t.Errorf("expected 1/3, got %v", got)
+	}
+	if got := sharedTags(a, a); got != 1 {
+		t.Errorf("expected 1, got %v", got)
+	}
+	if got := sharedTags(a, nil); got != 0 {
+		t.Errorf("expected 0, got %v", got)
+	}
+}
@@ -0,0 +1,227 @@
+package bearblog
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+)
+
+const (
+	// tocMarker is replaced with the table of contents when it's on a line by
+	// itself in a post.
+	tocMarker = "{{toc}}"
+	// wordsPerMinute is the reading speed used for the reading time.
+	wordsPerMinute = 200
+)
+
+var (
+	// headingIDPattern matches the heading IDs generated by blackfriday so
+	// they can be allowed through the sanitizer.
+	headingIDPattern = regexp.MustCompile(`^[\p{L}\p{N}-]+$`)
+
+	// postPolicy is the UGC policy with IDs allowed on headings in any
+	// language. The UGC policy removes IDs without ASCII letters or numbers
+	// so headings like "日本語" would lose their anchors.
+	postPolicy = func() *bluemonday.Policy {
+		policy := bluemonday.UGCPolicy()
+		policy.AllowAttrs("id").Matching(headingIDPattern).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+		return policy
+	}()
+)
+
+// heading is a heading in a post.
+type heading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// renderPost converts the Markdown of a post to HTML with an ID on each
+// heading. Raw HTML is removed unless it's allowed. The table of contents
+// replaces the marker if there is one, otherwise it's added to the top when
+// toc is true.
+func renderPost(content string, allowHTML bool, toc bool) string {
+	// Ensure unit line endings are used when pulling out of JSON.
+	markdownWithUnixLineEndings := strings.Replace(content, "\r\n", "\n", -1)
+
+	parser := blackfriday.New(blackfriday.WithExtensions(blackfriday.CommonExtensions | blackfriday.AutoHeadingIDs))
+	ast := parser.Parse([]byte(markdownWithUnixLineEndings))
+	headings := headingIDs(ast)
+
+	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags,
+	})
+
+	var buf bytes.Buffer
+	renderer.RenderHeader(&buf, ast)
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		return renderer.RenderNode(&buf, node, entering)
+	})
+	renderer.RenderFooter(&buf, ast)
+
+	htmlCode := buf.Bytes()
+	if !allowHTML {
+		htmlCode = postPolicy.SanitizeBytes(htmlCode)
+	}
+
+	marker := "<p>" + tocMarker + "</p>"
+	out := string(htmlCode)
+	if strings.Contains(out, marker) {
+		return strings.Replace(out, marker, tocHTML(headings), 1)
+	} else if toc {
+		return tocHTML(headings) + out
+	}
+
+	return out
+}
+
+// headingIDs makes the IDs of the headings unique and returns the headings in
+// order.
+func headingIDs(ast *blackfriday.Node) []heading {
+	arr := make([]heading, 0)
+	used := make(map[string]bool)
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering || node.Type != blackfriday.Heading || node.IsTitleblock {
+			return blackfriday.GoToNext
+		}
+
+		base := node.HeadingID
+		if len(base) == 0 {
+			base = "section"
+		}
+
+		// Add a number to headings with the same text.
+		ID := base
+		for i := 1; used[ID]; i++ {
+			ID = fmt.Sprintf("%v-%v", base, i)
+		}
+		used[ID] = true
+		node.HeadingID = ID
+
+		arr = append(arr, heading{Level: node.Level, ID: ID, Text: headingText(node)})
+		return blackfriday.SkipChildren
+	})
+
+	return arr
+}
+
+// headingText returns the text of a heading without formatting.
+func headingText(node *blackfriday.Node) string {
+	var b strings.Builder
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (n.Type == blackfriday.Text || n.Type == blackfriday.Code) {
+			b.Write(n.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+
+	return strings.TrimSpace(b.String())
+}
+
+// tocHTML returns a nested list of links to the headings. An empty string is
+// returned if there are no headings.
+func tocHTML(headings []heading) string {
+	if len(headings) == 0 {
+		return ""
+	}
+
+	// Start from the highest level heading so the list isn't indented when
+	// the post doesn't use h1.
+	top := headings[0].Level
+	for _, v := range headings {
+		if v.Level < top {
+			top = v.Level
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<nav class="toc"><ul>`)
+	depth := 0
+	for i, v := range headings {
+		level := v.Level - top
+		if i > 0 {
+			switch {
+			case level > depth:
+				// Only indent one level at a time so skipped levels don't
+				// create empty items.
+				level = depth + 1
+				b.WriteString("<ul>")
+			case level < depth:
+				for ; depth > level; depth-- {
+					b.WriteString("</li></ul>")
+				}
+				b.WriteString("</li>")
+			default:
+				b.WriteString("</li>")
+			}
+		} else {
+			level = 0
+		}
+		depth = level
+
+		fmt.Fprintf(&b, `<li><a href="#%v">%v</a>`, html.EscapeString(v.ID), html.EscapeString(v.Text))
+	}
+	for ; depth > 0; depth-- {
+		b.WriteString("</li></ul>")
+	}
+	b.WriteString("</li></ul></nav>")
+
+	return b.String()
+}
+
+// wordCount returns the number of words in the Markdown content.
+func wordCount(content string) int {
+	return len(strings.Fields(plaintext(content)))
+}
+
+// readingTime returns the number of minutes it takes to read the words. The
+// minimum is one minute.
+func readingTime(words int) int {
+	minutes := int(math.Ceil(float64(words) / wordsPerMinute))
+	if minutes < 1 {
+		return 1
+	}
+
+	return minutes
+}
+
+// postTOC returns true if the table of contents is turned on for the post.
+func (p *Plugin) postTOC(ID string) (bool, error) {
+	all := make(map[string]bool)
+	err := p.loadData(dataTOC, &all)
+	if err != nil {
+		return false, err
+	}
+
+	return all[ID], nil
+}
+
+// setPostTOC turns the table of contents on or off for the post.
+func (p *Plugin) setPostTOC(ID string, value bool) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]bool)
+	err := p.loadData(dataTOC, &all)
+	if err != nil {
+		return err
+	}
+
+	if all[ID] == value {
+		return nil
+	}
+
+	if value {
+		all[ID] = true
+	} else {
+		delete(all, ID)
+	}
+
+	return p.saveData(dataTOC, all)
+}
@@ -0,0 +1,75 @@
+package bearblog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderPostHeadingIDs(t *testing.T) {
+	out := renderPost("# Intro\n\n## Setup\n\n## Setup\n\ntext", false, false)
+
+	for _, expected := range []string{`<h1 id="intro">`, `<h2 id="setup">`, `<h2 id="setup-1">`} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in %q", expected, out)
+		}
+	}
+}
+
+func TestRenderPostSanitized(t *testing.T) {
+	out := renderPost("## Title\n\n## 日本語\n\n<h2 onclick=\"x()\">Raw</h2><script>alert(1)</script>", false, false)
+	if strings.Contains(out, "<script>") || strings.Contains(out, "onclick") {
+		t.Errorf("unsafe HTML was not removed: %q", out)
+	}
+	for _, expected := range []string{`<h2 id="title">`, `<h2 id="日本語">`} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("heading ID was removed, expected %q in %q", expected, out)
+		}
+	}
+}
+
+func TestRenderPostTOC(t *testing.T) {
+	content := "Intro text.\n\n{{toc}}\n\n## One\n\n### One A\n\n## Two"
+	out := renderPost(content, false, false)
+	expected := `<nav class="toc"><ul><li><a href="#one">One</a><ul><li><a href="#one-a">One A</a></li></ul></li><li><a href="#two">Two</a></li></ul></nav>`
+	if !strings.Contains(out, expected) {
+		t.Errorf("marker: expected %q in %q", expected, out)
+	}
+	if strings.Index(out, "Intro text.") > strings.Index(out, `<nav class="toc">`) {
+		t.Errorf("marker: table of contents should be after the intro: %q", out)
+	}
+
+	out = renderPost("## One\n\n## Two", false, true)
+	if !strings.HasPrefix(out, `<nav class="toc">`) {
+		t.Errorf("toggle: expected table of contents at the top: %q", out)
+	}
+
+	out = renderPost("## One", false, false)
+	if strings.Contains(out, "toc") {
+		t.Errorf("off: unexpected table of contents: %q", out)
+	}
+}
+
+func TestTOCHTMLSkippedLevels(t *testing.T) {
+	out := tocHTML([]heading{
+		{Level: 2, ID: "a", Text: "A"},
+		{Level: 4, ID: "b", Text: "B <b>"},
+		{Level: 2, ID: "c", Text: "C"},
+	})
+	expected := `<nav class="toc"><ul><li><a href="#a">A</a><ul><li><a href="#b">B &lt;b&gt;</a></li></ul></li><li><a href="#c">C</a></li></ul></nav>`
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := map[int]int{0: 1, 1: 1, 200: 1, 201: 2, 1000: 5}
+	for words, expected := range tests {
+		if got := readingTime(words); got != expected {
+			t.Errorf("readingTime(%v): expected %v, got %v", words, expected, got)
+		}
+	}
+
+	if got := wordCount("Hello **big** world.\n\n{{toc}}"); got != 3 {
+		t.Errorf("wordCount: expected 3, got %v", got)
+	}
+}
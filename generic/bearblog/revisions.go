@@ -0,0 +1,183 @@
+package bearblog
+
+import (
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+const (
+	// maxRevisions is the number of revisions kept for each post. The oldest
+	// revisions are removed first.
+	maxRevisions = 25
+	// maxRevisionBytes is the most content kept in the revisions of a post.
+	// The oldest revisions are removed first but the newest one is always
+	// kept.
+	maxRevisionBytes = 1 << 20
+	// maxRevisionTotalBytes is the most content kept in the revisions of all
+	// posts since they are saved in the site storage.
+	maxRevisionTotalBytes = 8 << 20
+)
+
+// revision is a saved copy of a post.
+type revision struct {
+	Number    int             `json:"number"`
+	Title     string          `json:"title"`
+	URL       string          `json:"url"`
+	Content   string          `json:"content"`
+	Tags      ambient.TagList `json:"tags"`
+	Timestamp time.Time       `json:"timestamp"`
+	Saved     time.Time       `json:"saved"`
+	Username  string          `json:"username"`
+}
+
+// revisions returns the revisions of a post from oldest to newest.
+func (p *Plugin) revisions(postID string) ([]revision, error) {
+	all := make(map[string][]revision)
+	err := p.loadData(dataRevisions, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	arr, ok := all[postID]
+	if !ok {
+		return []revision{}, nil
+	}
+
+	return arr, nil
+}
+
+// revision returns a single revision of a post.
+func (p *Plugin) revision(postID string, number int) (revision, bool, error) {
+	arr, err := p.revisions(postID)
+	if err != nil {
+		return revision{}, false, err
+	}
+
+	for _, v := range arr {
+		if v.Number == number {
+			return v, true, nil
+		}
+	}
+
+	return revision{}, false, nil
+}
+
+// recordRevision adds the current state of a post to its revision log. If
+// onlyIfEmpty is true, the revision is only added when the post has no
+// revisions yet which is used to keep the original content of posts that
+// were created before revisions were tracked.
+func (p *Plugin) recordRevision(postID string, post ambient.Post, username string, onlyIfEmpty bool) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string][]revision)
+	err := p.loadData(dataRevisions, &all)
+	if err != nil {
+		return err
+	}
+
+	arr := all[postID]
+	if onlyIfEmpty && len(arr) > 0 {
+		return nil
+	}
+
+	number := 1
+	if len(arr) > 0 {
+		number = arr[len(arr)-1].Number + 1
+	}
+
+	arr = append(arr, revision{
+		Number:    number,
+		Title:     post.Title,
+		URL:       post.URL,
+		Content:   post.Content,
+		Tags:      post.Tags,
+		Timestamp: post.Timestamp,
+		Saved:     time.Now(),
+		Username:  username,
+	})
+
+	all[postID] = trimRevisions(arr)
+	trimAllRevisions(all, postID)
+
+	return p.saveData(dataRevisions, all)
+}
+
+// trimRevisions removes the oldest revisions so there are no more than
+// maxRevisions and their content is no larger than maxRevisionBytes.
+func trimRevisions(arr []revision) []revision {
+	if len(arr) > maxRevisions {
+		arr = arr[len(arr)-maxRevisions:]
+	}
+
+	size := 0
+	for i := len(arr) - 1; i >= 0; i-- {
+		size += revisionSize(arr[i])
+		if size > maxRevisionBytes && i < len(arr)-1 {
+			return arr[i+1:]
+		}
+	}
+
+	return arr
+}
+
+// trimAllRevisions removes the oldest revisions of all posts until their
+// content is no larger than maxRevisionTotalBytes so the site storage stays
+// small. The newest revision of each post is always kept. The revisions of
+// keepID are removed last since they were just saved.
+func trimAllRevisions(all map[string][]revision, keepID string) {
+	size := 0
+	for _, arr := range all {
+		for _, v := range arr {
+			size += revisionSize(v)
+		}
+	}
+
+	for size > maxRevisionTotalBytes {
+		oldest := ""
+		for ID, arr := range all {
+			if len(arr) < 2 || ID == keepID {
+				continue
+			}
+			if len(oldest) == 0 || arr[0].Saved.Before(all[oldest][0].Saved) {
+				oldest = ID
+			}
+		}
+
+		if len(oldest) == 0 {
+			if len(all[keepID]) < 2 {
+				return
+			}
+			oldest = keepID
+		}
+
+		size -= revisionSize(all[oldest][0])
+		all[oldest] = all[oldest][1:]
+	}
+}
+
+// revisionSize returns the size of the content of a revision.
+func revisionSize(v revision) int {
+	return len(v.Title) + len(v.Content)
+}
+
+// deleteRevisions removes the revision log of a post.
+func (p *Plugin) deleteRevisions(postID string) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string][]revision)
+	err := p.loadData(dataRevisions, &all)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := all[postID]; !ok {
+		return nil
+	}
+
+	delete(all, postID)
+
+	return p.saveData(dataRevisions, all)
+}
@@ -0,0 +1,57 @@
+package bearblog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrimRevisions(t *testing.T) {
+	arr := make([]revision, 0)
+	for i := 1; i <= maxRevisions+5; i++ {
+		arr = append(arr, revision{Number: i, Content: "small"})
+	}
+
+	got := trimRevisions(arr)
+	if len(got) != maxRevisions || got[0].Number != 6 {
+		t.Fatalf("expected the newest %v revisions, got %v starting at %v", maxRevisions, len(got), got[0].Number)
+	}
+
+	// The oldest revisions are removed when the content is too large.
+	big := strings.Repeat("x", maxRevisionBytes/2)
+	got = trimRevisions([]revision{{Number: 1, Content: big}, {Number: 2, Content: big}, {Number: 3, Content: "new"}})
+	if len(got) != 2 || got[0].Number != 2 {
+		t.Fatalf("expected revisions 2 and 3, got %v", len(got))
+	}
+
+	// The newest revision is kept even if it's too large.
+	got = trimRevisions([]revision{{Number: 1, Content: "old"}, {Number: 2, Content: big + big}})
+	if len(got) != 1 || got[0].Number != 2 {
+		t.Fatalf("expected only the newest revision, got %v", len(got))
+	}
+}
+
+func TestTrimAllRevisions(t *testing.T) {
+	big := strings.Repeat("x", maxRevisionTotalBytes/4)
+	now := time.Now()
+	all := map[string][]revision{
+		"a": {{Number: 1, Content: big, Saved: now.Add(-3 * time.Hour)}, {Number: 2, Content: "a", Saved: now}},
+		"b": {{Number: 1, Content: big, Saved: now.Add(-2 * time.Hour)}, {Number: 2, Content: big, Saved: now}},
+		"c": {{Number: 1, Content: big, Saved: now.Add(-time.Hour)}, {Number: 2, Content: big, Saved: now}},
+	}
+
+	trimAllRevisions(all, "c")
+	if len(all["a"]) != 1 || len(all["b"]) != 1 || len(all["c"]) != 2 {
+		t.Fatalf("expected the oldest revisions of a and b to be removed, got %v, %v, %v", len(all["a"]), len(all["b"]), len(all["c"]))
+	}
+
+	// The newest revision of each post is always kept.
+	all = map[string][]revision{
+		"a": {{Number: 1, Content: big + big + big, Saved: now}},
+		"b": {{Number: 1, Content: big + big, Saved: now}},
+	}
+	trimAllRevisions(all, "a")
+	if len(all["a"]) != 1 || len(all["b"]) != 1 {
+		t.Fatalf("expected the newest revisions to be kept, got %v, %v", len(all["a"]), len(all["b"]))
+	}
+}
@@ -0,0 +1,404 @@
+package bearblog
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/pkg/uuid"
+)
+
+// maxAPIBody is the largest request body the API accepts.
+const maxAPIBody = 1 << 20
+
+// apiSite is the site information that can be read and changed through the
+// API.
+type apiSite struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+func (p *Plugin) apiToken(w http.ResponseWriter, r *http.Request) (err error) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		MFA      string `json:"mfa"`
+	}
+	if err := decodeAPIBody(w, r, &req); err != nil {
+		return apiError(w, http.StatusBadRequest, err.Error())
+	}
+
+	// Share the brute force protection with the login page.
+	limitKeys := []string{"ip:" + p.clientIP(r), "user:" + strings.ToLower(req.Username)}
+	if wait := p.loginLimiter.locked(limitKeys...); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return apiError(w, http.StatusTooManyRequests, "too many failed attempts")
+	}
+
+	err = p.authenticate(req.Username, req.Password, req.MFA)
+	if err == errLoginFailed || err == errMFAFailed {
+		locked := p.loginLimiter.fail(limitKeys...)
+		if err == errMFAFailed {
+			p.audit(r, auditMFAFailure, req.Username, "api")
+		} else {
+			p.audit(r, auditLoginFailure, req.Username, "api")
+		}
+		if locked {
+			p.audit(r, auditLoginLocked, req.Username, "api")
+		}
+		return apiError(w, http.StatusUnauthorized, err.Error())
+	} else if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	p.loginLimiter.succeed(limitKeys...)
+
+	webtoken, err := p.apiWebtoken()
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	token, err := webtoken.Generate(req.Username)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	p.audit(r, auditAPIToken, req.Username, "")
+
+	return writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token":   token,
+		"expires": time.Now().Add(apiTokenLifetime),
+	})
+}
+
+func (p *Plugin) apiPostIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	cu, ok, err := p.apiUser(r)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if !ok {
+		return apiError(w, http.StatusUnauthorized, "user no longer exists")
+	}
+
+	visible, err := p.apiVisiblePosts(cu)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	q := r.URL.Query()
+	arr := filterPosts(visible, q.Get("status"), q.Get("tag"))
+	sortPosts(arr, q.Get("sort"))
+
+	return writeJSON(w, http.StatusOK, arr)
+}
+
+func (p *Plugin) apiPostShow(w http.ResponseWriter, r *http.Request) (err error) {
+	ID := p.Mux.Param(r, "id")
+
+	post, status, msg, err := p.apiEditablePost(r, ID)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if status != http.StatusOK {
+		return apiError(w, status, msg)
+	}
+
+	return writeJSON(w, http.StatusOK, ambient.PostWithID{ID: ID, Post: post})
+}
+
+func (p *Plugin) apiPostStore(w http.ResponseWriter, r *http.Request) (err error) {
+	cu, ok, err := p.apiUser(r)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if !ok {
+		return apiError(w, http.StatusUnauthorized, "user no longer exists")
+	}
+
+	var post ambient.Post
+	if err := decodeAPIBody(w, r, &post); err != nil {
+		return apiError(w, http.StatusBadRequest, err.Error())
+	}
+
+	ID, err := uuid.Generate()
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	now := time.Now()
+	post.Created = now
+	post.Updated = now
+	prepareAPIPost(&post, now)
+
+	slug, msg, err := p.checkSlug(ID, strings.TrimSpace(post.URL), post.Title)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if len(msg) > 0 {
+		return apiError(w, http.StatusBadRequest, msg)
+	}
+	post.URL = slug
+
+	err = p.savePost(ID, post)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	err = p.recordSlugChange(ID, "", post.URL)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	err = p.setPostAuthor(ID, cu.Username)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	err = p.recordRevision(ID, post, cu.Username, false)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	return writeJSON(w, http.StatusCreated, ambient.PostWithID{ID: ID, Post: post})
+}
+
+func (p *Plugin) apiPostUpdate(w http.ResponseWriter, r *http.Request) (err error) {
+	ID := p.Mux.Param(r, "id")
+
+	post, status, msg, err := p.apiEditablePost(r, ID)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if status != http.StatusOK {
+		return apiError(w, status, msg)
+	}
+
+	// Keep the content from before revisions were tracked.
+	err = p.recordRevision(ID, post, "", true)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	// Fields that are left out of the request are not changed.
+	oldSlug := post.URL
+	created := post.Created
+	if err := decodeAPIBody(w, r, &post); err != nil {
+		return apiError(w, http.StatusBadRequest, err.Error())
+	}
+
+	now := time.Now()
+	post.Created = created
+	post.Updated = now
+	prepareAPIPost(&post, now)
+
+	slug, msg, err := p.checkSlug(ID, strings.TrimSpace(post.URL), post.Title)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if len(msg) > 0 {
+		return apiError(w, http.StatusBadRequest, msg)
+	}
+	post.URL = slug
+
+	err = p.savePost(ID, post)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	err = p.recordSlugChange(ID, oldSlug, post.URL)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	username, _ := p.Site.AuthenticatedUser(r)
+	err = p.recordRevision(ID, post, username, false)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	return writeJSON(w, http.StatusOK, ambient.PostWithID{ID: ID, Post: post})
+}
+
+func (p *Plugin) apiPostDestroy(w http.ResponseWriter, r *http.Request) (err error) {
+	ID := p.Mux.Param(r, "id")
+
+	_, status, msg, err := p.apiEditablePost(r, ID)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if status != http.StatusOK {
+		return apiError(w, status, msg)
+	}
+
+	username, _ := p.Site.AuthenticatedUser(r)
+	err = p.trashPost(ID, username)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func (p *Plugin) apiTagIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	cu, ok, err := p.apiUser(r)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if !ok {
+		return apiError(w, http.StatusUnauthorized, "user no longer exists")
+	}
+
+	// Only list the tags of the posts the user can see so tags of other
+	// authors' drafts aren't shown.
+	visible, err := p.apiVisiblePosts(cu)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	return writeJSON(w, http.StatusOK, liveTags(visible))
+}
+
+func (p *Plugin) apiSiteShow(w http.ResponseWriter, r *http.Request) (err error) {
+	_, ok, err := p.apiUser(r)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if !ok {
+		return apiError(w, http.StatusUnauthorized, "user no longer exists")
+	}
+
+	site, err := p.apiSite()
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	return writeJSON(w, http.StatusOK, site)
+}
+
+func (p *Plugin) apiSiteUpdate(w http.ResponseWriter, r *http.Request) (err error) {
+	cu, ok, err := p.apiUser(r)
+	if err != nil {
+		return p.apiServerError(w, err)
+	} else if !ok {
+		return apiError(w, http.StatusUnauthorized, "user no longer exists")
+	} else if cu.Role != roleAdmin {
+		return apiError(w, http.StatusForbidden, "only admins can change the site")
+	}
+
+	site, err := p.apiSite()
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	// Fields that are left out of the request are not changed.
+	if err := decodeAPIBody(w, r, &site); err != nil {
+		return apiError(w, http.StatusBadRequest, err.Error())
+	}
+
+	err = p.Site.SetTitle(site.Title)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	err = p.Site.SetContent(site.Content)
+	if err != nil {
+		return p.apiServerError(w, err)
+	}
+
+	return writeJSON(w, http.StatusOK, site)
+}
+
+// apiSite returns the current site title and home page content.
+func (p *Plugin) apiSite() (apiSite, error) {
+	title, err := p.Site.Title()
+	if err != nil {
+		return apiSite{}, err
+	}
+
+	content, err := p.Site.Content()
+	if err != nil {
+		return apiSite{}, err
+	}
+
+	return apiSite{Title: title, Content: content}, nil
+}
+
+// apiVisiblePosts returns the posts and pages the user of an API request can
+// see. Authors only see their own posts.
+func (p *Plugin) apiVisiblePosts(cu currentUser) (ambient.PostWithIDList, error) {
+	postsAndPages, err := p.postsAndPages(false)
+	if err != nil {
+		return nil, err
+	}
+
+	authors, err := p.postAuthors()
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(ambient.PostWithIDList, 0)
+	for _, v := range postsAndPages {
+		if cu.Role == roleAuthor && authors[v.ID] != cu.Username {
+			continue
+		}
+		visible = append(visible, v)
+	}
+
+	return visible, nil
+}
+
+// apiEditablePost returns the post with the ID if the user of the API
+// request can edit it. If they can't, the status and message explain why.
+func (p *Plugin) apiEditablePost(r *http.Request, ID string) (ambient.Post, int, string, error) {
+	cu, ok, err := p.apiUser(r)
+	if err != nil {
+		return ambient.Post{}, 0, "", err
+	} else if !ok {
+		return ambient.Post{}, http.StatusUnauthorized, "user no longer exists", nil
+	}
+
+	allowed, err := p.canEditPost(cu, ID)
+	if err != nil {
+		return ambient.Post{}, 0, "", err
+	} else if !allowed {
+		return ambient.Post{}, http.StatusForbidden, "post can't be edited by the user", nil
+	}
+
+	post, err := p.postByID(ID)
+	if err != nil {
+		return ambient.Post{}, http.StatusNotFound, "post not found", nil
+	}
+
+	return post, http.StatusOK, "", nil
+}
+
+// prepareAPIPost fills in the fields of a post from the API that weren't
+// set. Posts without a publish date are published now.
+func prepareAPIPost(post *ambient.Post, now time.Time) {
+	if post.Timestamp.IsZero() {
+		post.Timestamp = now
+	}
+
+	tags := make(ambient.TagList, 0, len(post.Tags))
+	for _, v := range post.Tags {
+		v.Name = strings.TrimSpace(v.Name)
+		if len(v.Name) == 0 || hasTag(tags, v.Name) {
+			continue
+		}
+		if v.Timestamp.IsZero() {
+			v.Timestamp = now
+		}
+		tags = append(tags, v)
+	}
+	post.Tags = tags
+}
+
+// decodeAPIBody reads the JSON request body into v.
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBody)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err != nil {
+		return errors.New("request body is not valid JSON: " + err.Error())
+	}
+
+	return nil
+}
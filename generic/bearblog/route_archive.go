@@ -0,0 +1,124 @@
+package bearblog
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+// postArchiveIndex lists the posts in the archive of a tag, series, or month.
+// The archives share one route since some routers match parameters before
+// static segments so a route for /blog/{year}/{month} would also match the
+// tag and series archives.
+func (p *Plugin) postArchiveIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	section := p.Mux.Param(r, "section")
+	name := p.Mux.Param(r, "name")
+
+	switch section {
+	case "tag":
+		return p.renderTagIndex(w, r, name)
+	case "series":
+		return p.renderSeriesIndex(w, r, name)
+	}
+
+	return p.renderMonthIndex(w, r, section, name)
+}
+
+// renderTagIndex lists the published posts and pages with a tag.
+func (p *Plugin) renderTagIndex(w http.ResponseWriter, r *http.Request, tag string) (err error) {
+	postsAndPages, err := p.livePostsAndPages()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	posts := make([]ambient.PostWithID, 0)
+	for _, v := range postsAndPages {
+		for _, t := range v.Tags {
+			if t.Name == tag {
+				posts = append(posts, v)
+				break
+			}
+		}
+	}
+
+	if len(posts) == 0 {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	vars := make(map[string]interface{})
+	vars["pagetitle"] = "#" + tag
+	vars["canonical"] = ""
+	vars["query"] = ""
+	vars["languages"] = []languageOption{}
+	vars["lang"] = ""
+	vars["heading"] = fmt.Sprintf("Posts tagged #%v", tag)
+
+	start, end, err := p.paginate(r, vars, tagURL(tag), len(posts))
+	if err != nil {
+		return err
+	}
+	vars["posts"] = posts[start:end]
+
+	return p.Render.Page(w, r, assets, "template/content/bloglist_index.tmpl", p.FuncMap(), vars)
+}
+
+// renderMonthIndex lists the published posts from a month.
+func (p *Plugin) renderMonthIndex(w http.ResponseWriter, r *http.Request, yearParam string, monthParam string) (err error) {
+	year, err := strconv.Atoi(yearParam)
+	if err != nil || year < 1 {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	month, err := strconv.Atoi(monthParam)
+	if err != nil || month < 1 || month > 12 {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	pubPosts, err := p.livePosts()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	posts := make([]ambient.Post, 0)
+	for _, v := range pubPosts {
+		if v.Timestamp.Year() == year && int(v.Timestamp.Month()) == month {
+			posts = append(posts, v)
+		}
+	}
+
+	if len(posts) == 0 {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	monthName := time.Month(month).String()
+
+	vars := make(map[string]interface{})
+	vars["pagetitle"] = fmt.Sprintf("%v %v", monthName, year)
+	vars["canonical"] = ""
+	vars["query"] = ""
+	vars["languages"] = []languageOption{}
+	vars["lang"] = ""
+	vars["heading"] = fmt.Sprintf("Posts from %v %v", monthName, year)
+
+	start, end, err := p.paginate(r, vars, monthURL(year, month), len(posts))
+	if err != nil {
+		return err
+	}
+	vars["posts"] = posts[start:end]
+
+	return p.Render.Page(w, r, assets, "template/content/bloglist_index.tmpl", p.FuncMap(), vars)
+}
+
+// tagURL returns the path to the archive page for a tag.
+func tagURL(tag string) string {
+	return "/blog/tag/" + url.PathEscape(tag)
+}
+
+// monthURL returns the path to the archive page for a month.
+func monthURL(year int, month int) string {
+	return fmt.Sprintf("/blog/%04d/%02d", year, month)
+}
@@ -0,0 +1,302 @@
+package bearblog
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/pkg/uuid"
+)
+
+// commentForm is the comment form on a post.
+type commentForm struct {
+	Name    string
+	Content string
+	Errors  []string
+}
+
+// commentView is a comment with its Markdown rendered to HTML.
+type commentView struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	HTML    string    `json:"html"`
+	Created time.Time `json:"created"`
+}
+
+// commentVars sets the template variables for the comments on a post.
+func (p *Plugin) commentVars(r *http.Request, vars map[string]interface{}, post ambient.PostWithID, form commentForm) error {
+	enabled, err := p.Site.PluginSettingBool(Comments)
+	if err != nil {
+		return err
+	}
+
+	closed, err := p.commentsClosed(post.ID)
+	if err != nil {
+		return err
+	}
+
+	key, err := p.secretKey()
+	if err != nil {
+		return err
+	}
+
+	views := make([]commentView, 0)
+	if enabled && !post.Page {
+		arr, err := p.comments(post.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range arr {
+			if v.Status != commentApproved {
+				continue
+			}
+
+			// Comments never allow raw HTML.
+			views = append(views, commentView{
+				ID:      v.ID,
+				Name:    v.Name,
+				HTML:    markdownHTML(v.Content, false),
+				Created: v.Created,
+			})
+		}
+	}
+
+	if form.Errors == nil {
+		form.Errors = []string{}
+	}
+
+	vars["commentsenabled"] = enabled && !post.Page
+	vars["commentsopen"] = !closed && post.Published && !scheduled(post.Post)
+	vars["comments"] = views
+	vars["commenttoken"] = commentToken(key, post.ID, time.Now())
+	vars["commentname"] = form.Name
+	vars["commentcontent"] = form.Content
+	vars["commenterrors"] = form.Errors
+	vars["commentstatus"] = r.URL.Query().Get("comment")
+
+	return nil
+}
+
+func (p *Plugin) commentStore(w http.ResponseWriter, r *http.Request) (err error) {
+	slug := p.Mux.Param(r, "slug")
+
+	post, err := p.postBySlug(slug)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	// Only published posts can be commented on.
+	if !post.Published || post.Page || scheduled(post.Post) {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	access, err := p.postAccess(post.ID)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	unlocked, err := p.unlocked(r, post.ID, access)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !unlocked {
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	enabled, err := p.Site.PluginSettingBool(Comments)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !enabled {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	closed, err := p.commentsClosed(post.ID)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if closed {
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	// Stop readers from flooding the site with comments. Users that are
+	// logged in are not limited.
+	_, err = p.Site.AuthenticatedUser(r)
+	anonymous := err != nil
+	ip := p.clientIP(r)
+	if anonymous {
+		if wait := p.commentLimiter.wait(ip); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return p.Mux.StatusError(http.StatusTooManyRequests, nil)
+		}
+	}
+
+	r.ParseForm()
+
+	// The honeypot field is hidden so only bots fill it in. Act like the
+	// comment was saved so they don't try again.
+	if len(r.FormValue("website")) > 0 {
+		p.Redirect(w, r, "/"+post.URL+"?comment="+commentPending+"#comments", http.StatusFound)
+		return
+	}
+
+	form := commentForm{
+		Name:    strings.TrimSpace(r.FormValue("name")),
+		Content: strings.TrimSpace(r.FormValue("comment")),
+		Errors:  make([]string, 0),
+	}
+
+	key, err := p.secretKey()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = checkCommentToken(key, post.ID, r.FormValue("comment_token"), time.Now())
+	if err == errCommentToken {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	} else if err != nil {
+		form.Errors = append(form.Errors, "The form was submitted too quickly or has expired, please try again.")
+	}
+
+	ID, err := uuid.Generate()
+	if err != nil {
+		return p.Mux.StatusError(http.StatusInternalServerError, err)
+	}
+
+	c := comment{
+		ID:      ID,
+		PostID:  post.ID,
+		Name:    form.Name,
+		Content: form.Content,
+		Status:  commentPending,
+		Created: time.Now(),
+	}
+
+	form.Errors = append(form.Errors, c.validate()...)
+	if len(form.Errors) > 0 {
+		return p.showPost(w, r, post, form)
+	}
+
+	// Comments from users that are logged in don't need to be approved.
+	if !anonymous {
+		c.Status = commentApproved
+	}
+
+	err = p.addComment(c)
+	if err == errCommentsFull {
+		form.Errors = append(form.Errors, "Too many comments are waiting for approval, please try again later.")
+		return p.showPost(w, r, post, form)
+	} else if err != nil {
+		return p.Site.Error(err)
+	}
+
+	// Each comment from a reader counts towards the limit.
+	if anonymous {
+		p.commentLimiter.add(ip)
+	}
+
+	p.Redirect(w, r, "/"+post.URL+"?comment="+c.Status+"#comments", http.StatusFound)
+	return
+}
+
+func (p *Plugin) commentIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin, roleEditor); err != nil {
+		return err
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case commentApproved, commentRejected:
+	default:
+		status = commentPending
+	}
+
+	arr, err := p.allComments(status)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	postsAndPages, err := p.postsAndPages(false)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	titles := make(map[string]string)
+	slugs := make(map[string]string)
+	for _, v := range postsAndPages {
+		titles[v.ID] = v.Title
+		slugs[v.ID] = v.URL
+	}
+
+	type adminComment struct {
+		commentView
+		PostTitle string `json:"posttitle"`
+		PostURL   string `json:"posturl"`
+	}
+
+	comments := make([]adminComment, 0, len(arr))
+	for _, v := range arr {
+		comments = append(comments, adminComment{
+			commentView: commentView{
+				ID:      v.ID,
+				Name:    v.Name,
+				HTML:    markdownHTML(v.Content, false),
+				Created: v.Created,
+			},
+			PostTitle: titles[v.PostID],
+			PostURL:   slugs[v.PostID],
+		})
+	}
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Comments"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["status"] = status
+	vars["comments"] = comments
+
+	return p.Render.Page(w, r, assets, "template/content/comments.tmpl", p.FuncMap(), vars)
+}
+
+func (p *Plugin) commentModerate(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin, roleEditor); err != nil {
+		return err
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	// The buttons on each comment send the action and ID together. The bulk
+	// action applies to all of the checked comments.
+	action := r.FormValue("action")
+	IDs := r.Form["ids"]
+	if single := strings.SplitN(r.FormValue("single"), ":", 2); len(single) == 2 {
+		action = single[0]
+		IDs = []string{single[1]}
+	}
+
+	var status string
+	switch action {
+	case "approve":
+		status = commentApproved
+	case "reject":
+		status = commentRejected
+	case "delete":
+		status = ""
+	default:
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	_, err = p.moderateComments(IDs, status)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	p.Redirect(w, r, "/dashboard/comments?status="+r.FormValue("status"), http.StatusFound)
+	return
+}
@@ -0,0 +1,97 @@
+package bearblog
+
+import (
+	"net/http"
+	"time"
+)
+
+// draftResponse is returned to the editor after a draft is saved or
+// discarded.
+type draftResponse struct {
+	Saved time.Time `json:"saved,omitempty"`
+	Error string    `json:"error,omitempty"`
+	Token string    `json:"token,omitempty"`
+}
+
+// previewResponse is returned to the editor with the rendered Markdown.
+type previewResponse struct {
+	HTML  string `json:"html"`
+	Error string `json:"error,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+func (p *Plugin) postAdminDraft(w http.ResponseWriter, r *http.Request) (err error) {
+	// The draft for a post that hasn't been created yet uses "new" as the ID.
+	ID := p.Mux.Param(r, "id")
+	if ID == "new" {
+		ID = ""
+	} else {
+		if err := p.authorizePost(r, ID); err != nil {
+			return err
+		}
+
+		_, err = p.postByID(ID)
+		if err != nil {
+			return p.Mux.StatusError(http.StatusNotFound, err)
+		}
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return writeJSON(w, http.StatusBadRequest, draftResponse{Error: "form has expired, reload the page"})
+	}
+
+	// Tokens can only be used once so send a new one for the next save.
+	token := p.Site.SetCSRF(r)
+
+	username, _ := p.Site.AuthenticatedUser(r)
+	key := draftKey(ID, username)
+
+	if r.FormValue("discard") == "true" {
+		err = p.deleteDraft(key)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		return writeJSON(w, http.StatusOK, draftResponse{Token: token})
+	}
+
+	d := draft{
+		Title:     r.FormValue("title"),
+		Slug:      r.FormValue("slug"),
+		Canonical: r.FormValue("canonical_url"),
+		Date:      r.FormValue("published_date"),
+		Content:   r.FormValue("content"),
+		Tags:      r.FormValue("tags"),
+		Page:      r.FormValue("is_page") == "on",
+		Published: r.FormValue("publish") == "on",
+		Username:  username,
+		Saved:     time.Now(),
+	}
+
+	err = p.saveDraft(key, d)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	return writeJSON(w, http.StatusOK, draftResponse{Saved: d.Saved, Token: token})
+}
+
+func (p *Plugin) postAdminPreview(w http.ResponseWriter, r *http.Request) (err error) {
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return writeJSON(w, http.StatusBadRequest, previewResponse{Error: "form has expired, reload the page"})
+	}
+
+	// Use the same rendering as the published post so the preview matches.
+	return writeJSON(w, http.StatusOK, previewResponse{
+		HTML:  p.sanitized(r.FormValue("content")),
+		Token: p.Site.SetCSRF(r),
+	})
+}
@@ -0,0 +1,116 @@
+package bearblog
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+func (p *Plugin) exportPosts(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin, roleEditor); err != nil {
+		return err
+	}
+
+	postsAndPages, err := p.postsAndPages(false)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	filename := fmt.Sprintf("posts-%v.zip", time.Now().Format("2006-01-02"))
+	return p.serveZip(w, filename, func(zw *zip.Writer) error {
+		used := make(map[string]bool)
+		for _, v := range postsAndPages {
+			b, err := encodeMarkdown(v.Post)
+			if err != nil {
+				return err
+			}
+
+			name := markdownFileName(v, used)
+			used[name] = true
+
+			f, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     name,
+				Method:   zip.Deflate,
+				Modified: v.Updated,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = f.Write(b)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (p *Plugin) exportStatic(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin, roleEditor); err != nil {
+		return err
+	}
+
+	// Pages are rendered by the running site unless the app handler is set.
+	fetch, err := p.dashboardFetcher()
+	if err != nil {
+		return p.Mux.StatusError(http.StatusBadRequest, err)
+	}
+
+	filename := fmt.Sprintf("site-%v.zip", time.Now().Format("2006-01-02"))
+	return p.serveZip(w, filename, func(zw *zip.Writer) error {
+		_, err := p.exportStaticSite(fetch, staticZip{zw: zw, now: time.Now()})
+		return err
+	})
+}
+
+// serveZip sends a zip file as a download. The whole zip is built before
+// anything is written so an error can still be shown instead of sending part
+// of the zip.
+func (p *Plugin) serveZip(w http.ResponseWriter, filename string, build func(zw *zip.Writer) error) error {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	err := build(zw)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v"`, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+
+	return nil
+}
+
+// dashboardFetcher returns the fetcher for a static export from the dashboard.
+// The site URL from the settings is used instead of the Host header of the
+// request since the client controls the header.
+func (p *Plugin) dashboardFetcher() (staticFetcher, error) {
+	if p.exportHandler != nil {
+		return handlerFetcher(p.exportHandler), nil
+	}
+
+	siteURL, err := p.Site.FullURL()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(siteURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, errors.New("bearblog: the site URL must be set to export the site")
+	}
+
+	return httpFetcher(u.Scheme + "://" + u.Host), nil
+}
@@ -22,6 +22,12 @@ func (p *Plugin) index(w http.ResponseWriter, r *http.Request) (err error) {
 }
 
 func (p *Plugin) edit(w http.ResponseWriter, r *http.Request) (err error) {
+	// Only admins can edit the site so send everyone else to their posts.
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		p.Redirect(w, r, "/dashboard/posts", http.StatusFound)
+		return nil
+	}
+
 	siteContent, err := p.Site.Content()
 	if err != nil {
 		return p.Site.Error(err)
@@ -79,6 +85,10 @@ func (p *Plugin) edit(w http.ResponseWriter, r *http.Request) (err error) {
 }
 
 func (p *Plugin) update(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
 	r.ParseForm()
 
 	// CSRF protection.
@@ -127,11 +137,17 @@ func (p *Plugin) update(w http.ResponseWriter, r *http.Request) (err error) {
 }
 
 func (p *Plugin) reload(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
 	err = p.Site.Load()
 	if err != nil {
 		p.Site.Error(err)
 	}
 
+	p.postsChanged()
+
 	p.Redirect(w, r, "/dashboard", http.StatusFound)
 	return
 }
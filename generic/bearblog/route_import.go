@@ -0,0 +1,302 @@
+package bearblog
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/pkg/uuid"
+)
+
+// maxImportSize is the largest zip file that can be uploaded.
+const maxImportSize = 32 << 20
+
+// maxImportFileSize is the largest Markdown file that is read from an import.
+// Zip files are compressed so the size of each file is limited too.
+const maxImportFileSize = 4 << 20
+
+// errImportFileSize is the reason a file in an import is too large.
+var errImportFileSize = errors.New("file is larger than 4 MB")
+
+// Actions for each file in an import.
+const (
+	importCreate   = "create"
+	importUpdate   = "update"
+	importConflict = "conflict"
+	importInvalid  = "invalid"
+)
+
+// importItem is a single file from an import and what will happen to it.
+type importItem struct {
+	File   string `json:"file"`
+	Title  string `json:"title"`
+	Slug   string `json:"slug"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+
+	id   string
+	post ambient.Post
+}
+
+func (p *Plugin) importPosts(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin, roleEditor); err != nil {
+		return err
+	}
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Import posts"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["items"] = []importItem{}
+	vars["dryrun"] = true
+	vars["done"] = false
+
+	return p.Render.Page(w, r, assets, "template/content/import.tmpl", p.FuncMap(), vars)
+}
+
+func (p *Plugin) importPostsPost(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin, roleEditor); err != nil {
+		return err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	err = r.ParseMultipartForm(maxImportSize)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusBadRequest, err)
+	}
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return p.Mux.StatusError(http.StatusBadRequest, err)
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusBadRequest, err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return p.Mux.StatusError(http.StatusBadRequest, err)
+	}
+
+	items, err := p.importPlan(zr)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	dryRun := r.FormValue("dry_run") == "on"
+	if !dryRun {
+		username, _ := p.Site.AuthenticatedUser(r)
+		err = p.applyImport(items, username)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, v := range items {
+		counts[v.Action]++
+	}
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Import posts"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["items"] = items
+	vars["dryrun"] = dryRun
+	vars["done"] = true
+	vars["creates"] = counts[importCreate]
+	vars["updates"] = counts[importUpdate]
+	vars["conflicts"] = counts[importConflict]
+	vars["invalid"] = counts[importInvalid]
+
+	return p.Render.Page(w, r, assets, "template/content/import.tmpl", p.FuncMap(), vars)
+}
+
+// importPlan reads the Markdown files in a zip and determines whether each
+// one creates a post, updates the post with the same slug, or can't be
+// imported.
+func (p *Plugin) importPlan(zr *zip.Reader) ([]importItem, error) {
+	postsAndPages, err := p.postsAndPages(false)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string][]string)
+	for _, v := range postsAndPages {
+		existing[v.URL] = append(existing[v.URL], v.ID)
+	}
+
+	items := make([]importItem, 0)
+	slugs := make(map[string]int)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !isMarkdownFile(f.Name) {
+			continue
+		}
+
+		item := importItem{
+			File: f.Name,
+		}
+
+		post, err := readMarkdownFile(f)
+		if err != nil {
+			item.Action = importInvalid
+			item.Reason = err.Error()
+			items = append(items, item)
+			continue
+		}
+
+		item.Title = post.Title
+		item.Slug = post.URL
+		if msg := validateSlug(post.URL); len(msg) > 0 {
+			item.Action = importInvalid
+			item.Reason = msg
+			items = append(items, item)
+			continue
+		}
+
+		item.post = post
+		slugs[post.URL]++
+
+		ids := existing[post.URL]
+		switch len(ids) {
+		case 0:
+			item.Action = importCreate
+		case 1:
+			item.Action = importUpdate
+			item.id = ids[0]
+		default:
+			item.Action = importConflict
+			item.Reason = fmt.Sprintf("slug is used by %v existing posts", len(ids))
+		}
+
+		items = append(items, item)
+	}
+
+	// Files that share a slug can't be imported since they would overwrite
+	// each other.
+	for i, v := range items {
+		if v.Action != importInvalid && slugs[v.Slug] > 1 {
+			items[i].Action = importConflict
+			items[i].Reason = fmt.Sprintf("slug is used by %v files in the import", slugs[v.Slug])
+		}
+	}
+
+	return items, nil
+}
+
+// applyImport saves the posts from an import plan. Conflicting and invalid
+// files are skipped.
+func (p *Plugin) applyImport(items []importItem, username string) error {
+	now := time.Now()
+
+	for _, v := range items {
+		post := v.post
+		for i := range post.Tags {
+			post.Tags[i].Timestamp = now
+		}
+		if post.Timestamp.IsZero() {
+			post.Timestamp = now
+		}
+		post.Updated = now
+
+		ID := v.id
+		switch v.Action {
+		case importCreate:
+			var err error
+			ID, err = uuid.Generate()
+			if err != nil {
+				return err
+			}
+			post.Created = now
+
+			err = p.setPostAuthor(ID, username)
+			if err != nil {
+				return err
+			}
+		case importUpdate:
+			current, err := p.postByID(ID)
+			if err != nil {
+				return err
+			}
+
+			// Keep the content from before revisions were tracked.
+			err = p.recordRevision(ID, current, "", true)
+			if err != nil {
+				return err
+			}
+
+			post.Created = current.Created
+		default:
+			continue
+		}
+
+		err := p.savePost(ID, post)
+		if err != nil {
+			return err
+		}
+
+		err = p.recordSlugChange(ID, "", post.URL)
+		if err != nil {
+			return err
+		}
+
+		err = p.recordRevision(ID, post, username, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readMarkdownFile returns the post in a Markdown file from a zip.
+func readMarkdownFile(f *zip.File) (ambient.Post, error) {
+	// The size in the zip can't be trusted so the reader is limited too.
+	if f.UncompressedSize64 > maxImportFileSize {
+		return ambient.Post{}, errImportFileSize
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return ambient.Post{}, err
+	}
+	defer rc.Close()
+
+	b, err := io.ReadAll(io.LimitReader(rc, maxImportFileSize+1))
+	if err != nil {
+		return ambient.Post{}, err
+	} else if len(b) > maxImportFileSize {
+		return ambient.Post{}, errImportFileSize
+	}
+
+	return decodeMarkdown(f.Name, b)
+}
+
+// isMarkdownFile returns true if the file name has a Markdown extension.
+// Files added by macOS when zipping a folder are ignored.
+func isMarkdownFile(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._") {
+		return false
+	}
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+
+	return false
+}
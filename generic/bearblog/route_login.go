@@ -1,12 +1,11 @@
 package bearblog
 
 import (
-	"encoding/base64"
+	"math"
 	"net/http"
 	"strconv"
-
-	"github.com/ambientkit/plugin/pkg/passhash"
-	"github.com/ambientkit/plugin/pkg/totp"
+	"strings"
+	"time"
 )
 
 // login allows a user to login to the dashboard.
@@ -51,61 +50,47 @@ func (p *Plugin) loginPost(w http.ResponseWriter, r *http.Request) (err error) {
 	mfa := r.FormValue("mfa")
 	remember := r.FormValue("remember")
 
-	allowedUsername, err := p.Site.PluginSettingString(Username)
-	if err != nil {
-		p.Site.Error(err)
-		return
-	}
-
-	allowedPassword, err := p.Site.PluginSettingString(Password)
-	if err != nil {
-		p.Site.Error(err)
-		return
-	}
-
-	mfakey, err := p.Site.PluginSettingString(MFAKey)
-	if err != nil {
-		p.Site.Error(err)
-		return
+	// Stop brute force attempts from an IP address or against a username.
+	limitKeys := []string{"ip:" + p.clientIP(r), "user:" + strings.ToLower(username)}
+	if wait := p.loginLimiter.locked(limitKeys...); wait > 0 {
+		// The lockout is audited when it starts so requests during the
+		// lockout don't write to storage.
+		p.Log.Info("bearblog: login attempt locked out for user: %v", username)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return p.Mux.StatusError(http.StatusTooManyRequests, nil)
 	}
 
-	// Get the MFA key - if the environment variable doesn't exist, then
-	// let the MFA pass.
-	mfaSuccess := true
-	if len(mfakey) > 0 {
-		imfa := 0
-		imfa, err = strconv.Atoi(mfa)
-		if err != nil {
-			p.Redirect(w, r, "/", http.StatusFound)
-			return
+	// If the username, password, or MFA don't match, then just redirect.
+	err = p.authenticate(username, password, mfa)
+	if err == errLoginFailed || err == errMFAFailed {
+		p.Log.Info("bearblog: login attempt failed for user: %v", username)
+		locked := p.loginLimiter.fail(limitKeys...)
+		if err == errMFAFailed {
+			p.audit(r, auditMFAFailure, username, "")
+		} else {
+			p.audit(r, auditLoginFailure, username, "")
 		}
-
-		mfaSuccess, err = totp.Authenticate(imfa, mfakey)
-		if err != nil {
-			return p.Mux.StatusError(http.StatusInternalServerError, err)
+		if locked {
+			p.audit(r, auditLoginLocked, username, "")
 		}
-	}
-
-	// Decode the hash - this is to allow it to be stored easily since dollar
-	// signs are difficult to work with.
-	hashDecoded, err := base64.StdEncoding.DecodeString(allowedPassword)
-	if err != nil {
+		p.Redirect(w, r, "/", http.StatusFound)
+		return nil
+	} else if err != nil {
 		return p.Mux.StatusError(http.StatusInternalServerError, err)
 	}
-	passMatch := passhash.MatchString(string(hashDecoded), password)
 
-	// If the username and password don't match, then just redirect.
-	if username != allowedUsername || !passMatch || !mfaSuccess {
-		p.Log.Info("bearblog: login attempt failed. Username: %v (expected: %v) | Password match: %v | MFA success: %v", username, allowedUsername, passMatch, mfaSuccess)
-		p.Redirect(w, r, "/", http.StatusFound)
-		return
-	}
+	p.loginLimiter.succeed(limitKeys...)
 
 	err = p.Site.UserLogin(r, username)
 	if err != nil {
 		p.Log.Info("bearblog: login attempt failed for '%v': %v", username, err.Error())
 	} else {
 		p.Log.Info("bearblog: login attempt successful for user: %v", username)
+		p.audit(r, auditLoginSuccess, username, "")
+		err = p.Site.SetSessionValue(r, sessionLoginTime, strconv.FormatInt(time.Now().UnixNano(), 10))
+		if err != nil {
+			p.Log.Info("bearblog: login time not saved for user '%v': %v", username, err.Error())
+		}
 	}
 	if remember == "on" {
 		err = p.Site.UserPersist(r, true)
@@ -122,9 +107,13 @@ func (p *Plugin) loginPost(w http.ResponseWriter, r *http.Request) (err error) {
 }
 
 func (p *Plugin) logout(w http.ResponseWriter, r *http.Request) (err error) {
+	username, _ := p.Site.AuthenticatedUser(r)
+
 	err = p.Site.UserLogout(r)
 	if err != nil {
 		p.Log.Info("bearblog: logout failed: %v", err.Error())
+	} else if len(username) > 0 {
+		p.audit(r, auditLogout, username, "")
 	}
 
 	p.Redirect(w, r, "/", http.StatusFound)
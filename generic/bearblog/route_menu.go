@@ -0,0 +1,101 @@
+package bearblog
+
+import (
+	"net/http"
+
+	"github.com/ambientkit/ambient"
+)
+
+func (p *Plugin) menuEdit(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
+	return p.menuForm(w, r, nil, nil)
+}
+
+func (p *Plugin) menuUpdate(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	posts, err := p.menuPosts()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	slugs := make(map[string]string)
+	for _, v := range posts {
+		slugs[v.URL] = v.ID
+	}
+
+	rows := menuFormRows(r.PostForm)
+	items, msg := buildMenu(rows, slugs)
+	if len(msg) > 0 {
+		return p.menuForm(w, r, rows, []string{msg})
+	}
+
+	err = p.setMenu(items)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	p.Redirect(w, r, "/dashboard/menu", http.StatusFound)
+	return
+}
+
+// menuForm renders the menu editor. The rows from a form that couldn't be
+// saved are shown instead of the saved menu.
+func (p *Plugin) menuForm(w http.ResponseWriter, r *http.Request, rows []menuRow, formErrors []string) error {
+	posts, err := p.menuPosts()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	byID := make(map[string]ambient.PostWithID)
+	for _, v := range posts {
+		byID[v.ID] = v
+	}
+
+	vars := make(map[string]interface{})
+	vars["custom"] = true
+	if rows == nil {
+		items, err := p.menu()
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		// Start from the menu that is shown now.
+		if len(items) == 0 {
+			vars["custom"] = false
+			items = defaultMenu(posts)
+		}
+		rows = menuRows(items, byID)
+	}
+
+	for i := 0; i < menuBlankRows; i++ {
+		rows = append(rows, menuRow{})
+	}
+
+	if formErrors == nil {
+		formErrors = []string{}
+	}
+
+	vars["title"] = "Menu"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["errors"] = formErrors
+	vars["rows"] = rows
+	vars["rowcount"] = len(rows)
+	vars["kinds"] = []string{menuPage, menuTag, menuLink}
+	vars["slugs"] = posts
+
+	return p.Render.Page(w, r, assets, "template/content/menu.tmpl", p.FuncMap(), vars)
+}
@@ -11,12 +11,20 @@ import (
 )
 
 func (p *Plugin) mfa(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
 	vars := make(map[string]interface{})
 	vars["title"] = "MFA Generate"
 	return p.Render.Page(w, r, assets, "template/content/mfa.tmpl", p.FuncMap(), vars)
 }
 
 func (p *Plugin) mfaPost(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
 	vars := make(map[string]interface{})
 	vars["title"] = "MFA Generate"
 
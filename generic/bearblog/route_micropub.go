@@ -0,0 +1,283 @@
+package bearblog
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/pkg/uuid"
+)
+
+func (p *Plugin) micropubQuery(w http.ResponseWriter, r *http.Request) (err error) {
+	if ok, err := p.micropubAuthorize(w, r); !ok {
+		return err
+	}
+
+	switch q := r.URL.Query().Get("q"); q {
+	case "config":
+		return writeJSON(w, http.StatusOK, map[string]interface{}{
+			"q":            []string{"config", "source"},
+			"syndicate-to": []string{},
+		})
+	case "source":
+		post, err := p.postBySlug(micropubSlug(r.URL.Query().Get("url")))
+		if err != nil {
+			return micropubFail(w, http.StatusBadRequest, "invalid_request", "post not found")
+		}
+
+		siteURL, err := p.Site.FullURL()
+		if err != nil {
+			return p.micropubServerError(w, err)
+		}
+
+		names := r.URL.Query()["properties[]"]
+		if len(names) == 0 {
+			names = r.URL.Query()["properties"]
+		}
+
+		resp := map[string]interface{}{
+			"properties": micropubSource(post.Post, siteURL+"/"+post.URL, names),
+		}
+		if len(names) == 0 {
+			resp["type"] = []string{"h-entry"}
+		}
+
+		return writeJSON(w, http.StatusOK, resp)
+	default:
+		return micropubFail(w, http.StatusBadRequest, "invalid_request", "query '"+q+"' is not supported")
+	}
+}
+
+func (p *Plugin) micropubPost(w http.ResponseWriter, r *http.Request) (err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBody)
+
+	var req micropubRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return micropubFail(w, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+
+		req, err = parseMicropubJSON(body)
+		if err != nil {
+			return micropubFail(w, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+	} else {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			err = r.ParseMultipartForm(maxAPIBody)
+		} else {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			return micropubFail(w, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+
+		req, err = parseMicropubForm(r.PostForm)
+		if err != nil {
+			return micropubFail(w, http.StatusBadRequest, "invalid_request", err.Error())
+		}
+	}
+
+	if ok, err := p.micropubAuthorize(w, r); !ok {
+		return err
+	}
+
+	// Posts from Micropub clients are credited to the site owner.
+	owner, err := p.ownerUsername()
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	switch req.Action {
+	case micropubCreate:
+		return p.micropubCreatePost(w, req, owner)
+	case micropubUpdate:
+		return p.micropubUpdatePost(w, req, owner)
+	case micropubDelete:
+		post, err := p.postBySlug(micropubSlug(req.URL))
+		if err != nil {
+			return micropubFail(w, http.StatusBadRequest, "invalid_request", "post not found")
+		}
+
+		err = p.trashPost(post.ID, owner)
+		if err != nil {
+			return p.micropubServerError(w, err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return micropubFail(w, http.StatusBadRequest, "invalid_request", "action '"+req.Action+"' is not supported")
+	}
+}
+
+func (p *Plugin) micropubCreatePost(w http.ResponseWriter, req micropubRequest, owner string) error {
+	ID, err := uuid.Generate()
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	now := time.Now()
+	post := ambient.Post{
+		Created:   now,
+		Updated:   now,
+		Published: true,
+	}
+
+	err = setMicropubProperties(&post, req.Properties, now)
+	if err != nil {
+		return micropubFail(w, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	prepareAPIPost(&post, now)
+
+	msg, err := p.setMicropubSlug(ID, &post)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	} else if len(msg) > 0 {
+		return micropubFail(w, http.StatusBadRequest, "invalid_request", msg)
+	}
+
+	err = p.savePost(ID, post)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	err = p.recordSlugChange(ID, "", post.URL)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	err = p.setPostAuthor(ID, owner)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	err = p.recordRevision(ID, post, owner, false)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	return p.micropubCreated(w, post)
+}
+
+func (p *Plugin) micropubUpdatePost(w http.ResponseWriter, req micropubRequest, owner string) error {
+	post, err := p.postBySlug(micropubSlug(req.URL))
+	if err != nil {
+		return micropubFail(w, http.StatusBadRequest, "invalid_request", "post not found")
+	}
+
+	// Keep the content from before revisions were tracked.
+	err = p.recordRevision(post.ID, post.Post, "", true)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	now := time.Now()
+	oldSlug := post.URL
+
+	err = setMicropubProperties(&post.Post, req.Replace, now)
+	if err == nil {
+		err = addMicropubProperties(&post.Post, req.Add, now)
+	}
+	if err != nil {
+		return micropubFail(w, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	deleteMicropubProperties(&post.Post, req.DeleteNames, req.DeleteValues)
+	post.Updated = now
+
+	msg, err := p.setMicropubSlug(post.ID, &post.Post)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	} else if len(msg) > 0 {
+		return micropubFail(w, http.StatusBadRequest, "invalid_request", msg)
+	}
+
+	err = p.savePost(post.ID, post.Post)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	err = p.recordSlugChange(post.ID, oldSlug, post.URL)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	err = p.recordRevision(post.ID, post.Post, owner, false)
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	// Let the client know the post moved.
+	if post.URL != oldSlug {
+		return p.micropubCreated(w, post.Post)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// setMicropubSlug sets the slug of a post from a Micropub client. Notes don't
+// have a title so the slug is made from the content instead. A message is
+// returned if the slug can't be used.
+func (p *Plugin) setMicropubSlug(ID string, post *ambient.Post) (string, error) {
+	title := post.Title
+	if len(title) == 0 {
+		title = post.Content
+	}
+
+	slug, msg, err := p.checkSlug(ID, strings.TrimSpace(post.URL), title)
+	if err != nil || len(msg) > 0 {
+		return msg, err
+	}
+	post.URL = slug
+
+	return "", nil
+}
+
+// micropubCreated sends the URL of the post in the Location header.
+func (p *Plugin) micropubCreated(w http.ResponseWriter, post ambient.Post) error {
+	siteURL, err := p.Site.FullURL()
+	if err != nil {
+		return p.micropubServerError(w, err)
+	}
+
+	w.Header().Set("Location", siteURL+"/"+post.URL)
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// micropubAuthorize returns true if the request has one of the Micropub
+// tokens. If it doesn't, the error is sent and the result of sending it is
+// returned.
+func (p *Plugin) micropubAuthorize(w http.ResponseWriter, r *http.Request) (bool, error) {
+	tokens, err := p.micropubTokens()
+	if err != nil {
+		return false, p.micropubServerError(w, err)
+	} else if len(tokens) == 0 {
+		// Micropub is turned off.
+		return false, p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	token := micropubToken(r)
+	if len(token) == 0 {
+		return false, micropubFail(w, http.StatusUnauthorized, "unauthorized", "access token is missing")
+	} else if !validMicropubToken(token, tokens) {
+		return false, micropubFail(w, http.StatusForbidden, "forbidden", "access token is invalid")
+	}
+
+	return true, nil
+}
+
+// micropubFail sends an error in the format from the Micropub specification.
+func micropubFail(w http.ResponseWriter, status int, code string, description string) error {
+	return writeJSON(w, status, micropubError{Error: code, Description: description})
+}
+
+// micropubServerError logs an error and sends a generic error so the details
+// are not shown to the client.
+func (p *Plugin) micropubServerError(w http.ResponseWriter, err error) error {
+	p.Log.Error("bearblog: micropub error: %v", err.Error())
+	return micropubFail(w, http.StatusInternalServerError, "server_error", "")
+}
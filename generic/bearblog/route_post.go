@@ -1,7 +1,9 @@
 package bearblog
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/ambientkit/ambient"
@@ -15,12 +17,25 @@ func (p *Plugin) postIndex(w http.ResponseWriter, r *http.Request) (err error) {
 	vars["pagetitle"] = ""
 	vars["canonical"] = ""
 	vars["query"] = ""
+	vars["heading"] = ""
 
-	tags, err := p.Site.Tags(true)
+	postsAndPages, err := p.livePostsAndPages()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+	vars["tags"] = liveTags(postsAndPages)
+
+	vars["languages"], err = p.languageOptions(postsAndPages)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	lang := normalizeLang(r.URL.Query().Get("lang"))
+	vars["lang"] = lang
+	inLang, err := p.inLanguage(lang)
 	if err != nil {
 		return p.Site.Error(err)
 	}
-	vars["tags"] = tags
 
 	// Determine if there is query.
 	if q := r.URL.Query().Get("q"); len(q) > 0 {
@@ -28,34 +43,38 @@ func (p *Plugin) postIndex(w http.ResponseWriter, r *http.Request) (err error) {
 		// Don't show tags when there is a filter.
 		delete(vars, "tags")
 
-		postsAndPages, err := p.Site.PostsAndPages(true)
+		all, err := p.search(q)
 		if err != nil {
 			return p.Site.Error(err)
 		}
 
-		posts := make([]ambient.PostWithID, 0)
-		for _, v := range postsAndPages {
-			match := false
-			for _, tag := range v.Tags {
-				if tag.Name == q {
-					match = true
-					break
-				}
+		results := make([]searchResult, 0, len(all))
+		for _, v := range all {
+			if inLang(v.ID) {
+				results = append(results, v)
 			}
+		}
 
-			if match {
-				posts = append(posts, v)
-			}
+		start, end, err := p.paginate(r, vars, "/blog", len(results))
+		if err != nil {
+			return err
 		}
 
-		vars["posts"] = posts
+		vars["posts"] = results[start:end]
 	} else {
-		pubPosts, err := p.Site.PublishedPosts()
+		pubPosts := make(ambient.PostWithIDList, 0)
+		for _, v := range postsAndPages {
+			if !v.Page && inLang(v.ID) {
+				pubPosts = append(pubPosts, v)
+			}
+		}
+
+		start, end, err := p.paginate(r, vars, "/blog", len(pubPosts))
 		if err != nil {
-			return p.Site.Error(err)
+			return err
 		}
 
-		vars["posts"] = pubPosts
+		vars["posts"] = pubPosts[start:end]
 	}
 
 	return p.Render.Page(w, r, assets, "template/content/bloglist_index.tmpl", p.FuncMap(), vars)
@@ -64,22 +83,126 @@ func (p *Plugin) postIndex(w http.ResponseWriter, r *http.Request) (err error) {
 func (p *Plugin) postShow(w http.ResponseWriter, r *http.Request) (err error) {
 	slug := p.Mux.Param(r, "slug")
 
-	post, err := p.Site.PostBySlug(slug)
+	post, err := p.postBySlug(slug)
+	if err != nil {
+		return p.redirectOldSlug(w, r, slug)
+	}
+
+	err = p.checkVisible(r, post)
+	if err != nil {
+		return err
+	}
+
+	access, err := p.postAccess(post.ID)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	unlocked, err := p.unlocked(r, post.ID, access)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !unlocked {
+		return p.showPasswordForm(w, r, post, false)
+	}
+
+	return p.showPost(w, r, post, commentForm{})
+}
+
+// postSubmit handles the forms on a post. The password form on a protected
+// post unlocks it and everything else is a comment.
+func (p *Plugin) postSubmit(w http.ResponseWriter, r *http.Request) (err error) {
+	r.ParseForm()
+	if _, ok := r.PostForm["post_password"]; ok {
+		return p.postUnlock(w, r)
+	}
+
+	return p.commentStore(w, r)
+}
+
+func (p *Plugin) postUnlock(w http.ResponseWriter, r *http.Request) (err error) {
+	slug := p.Mux.Param(r, "slug")
+
+	post, err := p.postBySlug(slug)
 	if err != nil {
 		return p.Mux.StatusError(http.StatusNotFound, nil)
 	}
 
-	// Determine if in preview mode.
-	preview := false
-	if q := r.URL.Query().Get("preview"); len(q) > 0 && strings.ToLower(q) == "true" {
-		preview = true
+	err = p.checkVisible(r, post)
+	if err != nil {
+		return err
+	}
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	access, err := p.postAccess(post.ID)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !access.protected() {
+		p.Redirect(w, r, "/"+post.URL, http.StatusFound)
+		return
 	}
 
-	// Show 404 if not published and not in preview mode.
-	if !post.Published && !preview {
+	// Stop brute force attempts against the password of a post.
+	limitKey := "unlock:" + post.ID + ":" + p.clientIP(r)
+	if wait := p.loginLimiter.locked(limitKey); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return p.Mux.StatusError(http.StatusTooManyRequests, nil)
+	}
+
+	ok, err := p.unlock(r, post.ID, access, r.FormValue("post_password"))
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !ok {
+		p.loginLimiter.fail(limitKey)
+		return p.showPasswordForm(w, r, post, true)
+	}
+
+	p.loginLimiter.succeed(limitKey)
+
+	u := "/" + post.URL
+	if len(r.URL.RawQuery) > 0 {
+		u += "?" + r.URL.RawQuery
+	}
+
+	p.Redirect(w, r, u, http.StatusFound)
+	return
+}
+
+// checkVisible returns a not found status error if the post isn't published
+// yet and it's not being previewed.
+func (p *Plugin) checkVisible(r *http.Request, post ambient.PostWithID) error {
+	if post.Published && !scheduled(post.Post) {
+		return nil
+	}
+
+	preview, err := p.canPreview(r, post.ID)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !preview {
 		return p.Mux.StatusError(http.StatusNotFound, nil)
 	}
 
+	return nil
+}
+
+// showPasswordForm renders the form to unlock a password protected post.
+func (p *Plugin) showPasswordForm(w http.ResponseWriter, r *http.Request, post ambient.PostWithID, failed bool) error {
+	vars := make(map[string]interface{})
+	vars["title"] = post.Title
+	vars["pagetitle"] = post.Title
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["failed"] = failed
+
+	return p.Render.Page(w, r, assets, "template/content/post_password.tmpl", p.FuncMap(), vars)
+}
+
+// showPost renders a post. The comment form is filled in with the values and
+// errors from a comment that couldn't be saved.
+func (p *Plugin) showPost(w http.ResponseWriter, r *http.Request, post ambient.PostWithID, form commentForm) (err error) {
 	vars := make(map[string]interface{})
 	// Don't show certain items on pages.
 	if !post.Page {
@@ -87,48 +210,145 @@ func (p *Plugin) postShow(w http.ResponseWriter, r *http.Request) (err error) {
 		vars["pubdate"] = post.Timestamp
 	}
 
+	author := ""
+	if !post.Page {
+		author, err = p.authorName(post.ID)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+	}
+	vars["author"] = author
+
 	vars["tags"] = post.Tags
 	vars["canonical"] = post.Canonical
 	vars["id"] = post.ID
 	vars["posturl"] = post.URL
 	vars["pagetitle"] = post.Title
-	vars["pagedescription"] = plaintextBlurb(post.Content)
-	vars["postcontent"] = p.sanitized(post.Content)
+	vars["postcontent"], err = p.sanitizedPost(post.ID, post.Content)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	words := wordCount(post.Content)
+	vars["wordcount"] = words
+	vars["readingtime"] = readingTime(words)
+
+	vars["series"] = false
+	if !post.Page {
+		nav, found, err := p.seriesNav(post)
+		if err != nil {
+			return p.Site.Error(err)
+		} else if found {
+			vars["series"] = nav
+		}
+	}
+
+	err = p.translationVars(vars, post)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.socialVars(vars, post, author)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.commentVars(r, vars, post, form)
+	if err != nil {
+		return p.Site.Error(err)
+	}
 
 	return p.Render.Post(w, r, assets, "template/content/post.tmpl", p.FuncMap(), vars)
 }
 
+// redirectOldSlug permanently redirects a previous slug of a post to the
+// current one. A 404 is returned if the slug was never used.
+func (p *Plugin) redirectOldSlug(w http.ResponseWriter, r *http.Request, slug string) error {
+	slugs, err := p.oldSlugs()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	ID, ok := slugs[slug]
+	if !ok {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	post, err := p.postByID(ID)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	u := "/" + post.URL
+	if len(r.URL.RawQuery) > 0 {
+		u += "?" + r.URL.RawQuery
+	}
+
+	p.Redirect(w, r, u, http.StatusMovedPermanently)
+	return nil
+}
+
 // plaintextBlurb returns a plaintext blurb from markdown content.
 func plaintextBlurb(s string) string {
+	text := plaintext(s)
+	period := strings.Index(text, ". ")
+	if period > 0 {
+		text = text[:period+1]
+	}
+
+	return text
+}
+
+// plaintext returns markdown content with all formatting removed.
+func plaintext(s string) string {
+	s = strings.Replace(s, tocMarker, "", -1)
 	unsafeHTML := blackfriday.Run([]byte(s))
-	plaintext, err := html2text.FromString(string(unsafeHTML))
+	text, err := html2text.FromString(string(unsafeHTML))
 	if err != nil {
-		plaintext = s
-	}
-	period := strings.Index(plaintext, ". ")
-	if period > 0 {
-		plaintext = plaintext[:period+1]
+		text = s
 	}
 
-	return plaintext
+	return text
 }
 
 // sanitized returns a sanitized content block or an error is one occurs. You
 // will still need to use {{.content | TrustHTML}} when rendering to a template.
 func (p *Plugin) sanitized(content string) string {
-	b := []byte(content)
-	// Ensure unit line endings are used when pulling out of JSON.
-	markdownWithUnixLineEndings := strings.Replace(string(b), "\r\n", "\n", -1)
-	htmlCode := blackfriday.Run([]byte(markdownWithUnixLineEndings))
-
 	// Determine if raw HTML is allowed.
 	allowed, err := p.Site.PluginSettingBool(AllowHTMLinMarkdown)
 	if err != nil {
 		p.Log.Debug("plugins: error in sanitized() getting plugin field: %v", err)
 	}
 
+	return renderPost(content, allowed, false)
+}
+
+// sanitizedPost returns the HTML of a post like sanitized() and adds the
+// table of contents to the top if it's turned on for the post.
+func (p *Plugin) sanitizedPost(ID string, content string) (string, error) {
+	allowed, err := p.Site.PluginSettingBool(AllowHTMLinMarkdown)
+	if err != nil {
+		p.Log.Debug("plugins: error in sanitizedPost() getting plugin field: %v", err)
+	}
+
+	toc, err := p.postTOC(ID)
+	if err != nil {
+		return "", err
+	}
+
+	return renderPost(content, allowed, toc), nil
+}
+
+// markdownHTML converts Markdown to HTML. Raw HTML is removed unless it's
+// allowed.
+func markdownHTML(content string, allowHTML bool) string {
+	b := []byte(content)
+	// Ensure unit line endings are used when pulling out of JSON.
+	markdownWithUnixLineEndings := strings.Replace(string(b), "\r\n", "\n", -1)
+	htmlCode := blackfriday.Run([]byte(markdownWithUnixLineEndings))
+
 	// Sanitize by removing HTML if allowed.
-	if !allowed {
+	if !allowHTML {
 		htmlCode = bluemonday.UGCPolicy().SanitizeBytes(htmlCode)
 	}
 
@@ -2,6 +2,8 @@ package bearblog
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ambientkit/ambient"
@@ -9,25 +11,176 @@ import (
 )
 
 func (p *Plugin) postAdminIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	return p.postList(w, r, "", []string{})
+}
+
+func (p *Plugin) postAdminBulk(w http.ResponseWriter, r *http.Request) (err error) {
+	cu, err := p.currentUser(r)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	action := r.PostFormValue("action")
+	tag := strings.TrimSpace(r.PostFormValue("bulk_tag"))
+	IDs := r.PostForm["ids"]
+	if !validBulkAction(action) || len(IDs) == 0 {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+	if (action == bulkAddTag || action == bulkRemoveTag) && len(tag) == 0 {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	// Check all of the posts before changing any of them.
+	for _, ID := range IDs {
+		allowed, err := p.canEditPost(cu, ID)
+		if err != nil {
+			return p.Site.Error(err)
+		} else if !allowed {
+			return p.Mux.StatusError(http.StatusForbidden, nil)
+		}
+	}
+
+	now := time.Now()
+	changed := make([]string, 0)
+	for _, ID := range IDs {
+		post, err := p.postByID(ID)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		if action == bulkDelete {
+			err = p.trashPost(ID, cu.Username)
+			if err != nil {
+				return p.Site.Error(err)
+			}
+			changed = append(changed, post.Title)
+			continue
+		}
+
+		// Keep the content from before revisions were tracked.
+		err = p.recordRevision(ID, post, "", true)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		if !applyBulkAction(&post, action, tag, now) {
+			continue
+		}
+
+		err = p.savePost(ID, post)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		err = p.recordRevision(ID, post, cu.Username, false)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		changed = append(changed, post.Title)
+	}
+
+	return p.postList(w, r, bulkSummary(action, tag, len(changed), len(IDs)), changed)
+}
+
+// postList renders the posts the user can edit with the filters and sort
+// order from the query string. The summary and titles of the changed posts
+// are shown after a bulk action.
+func (p *Plugin) postList(w http.ResponseWriter, r *http.Request, summary string, changed []string) error {
 	vars := make(map[string]interface{})
 	vars["title"] = "Posts"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["summary"] = summary
+	vars["changed"] = changed
+
+	postsAndPages, err := p.postsAndPages(false)
+	if err != nil {
+		return p.Site.Error(err)
+	}
 
-	postsAndPages, err := p.Site.PostsAndPages(false)
+	cu, err := p.currentUser(r)
 	if err != nil {
 		return p.Site.Error(err)
 	}
 
-	vars["posts"] = postsAndPages
+	// Link to the account page for users that are stored.
+	_, found, err := p.user(cu.Username)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+	vars["account"] = ""
+	if found {
+		vars["account"] = cu.Username
+	}
+	vars["admin"] = cu.Role == roleAdmin
+	vars["author"] = cu.Role == roleAuthor
+
+	authors, err := p.postAuthors()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	// Authors only see their own posts.
+	visible := make(ambient.PostWithIDList, 0)
+	for _, v := range postsAndPages {
+		if cu.Role == roleAuthor && authors[v.ID] != cu.Username {
+			continue
+		}
+		visible = append(visible, v)
+	}
+
+	q := r.URL.Query()
+	status := q.Get("status")
+	tag := q.Get("tag")
+	order := q.Get("sort")
+	if order != sortCreated && order != sortUpdated {
+		order = sortTimestamp
+	}
+	vars["status"] = status
+	vars["tag"] = tag
+	vars["sort"] = order
+	vars["tags"] = postTags(visible)
+
+	filtered := filterPosts(visible, status, tag)
+	sortPosts(filtered, order)
+
+	accesses, err := p.postAccesses()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	type adminPost struct {
+		ambient.PostWithID
+		Scheduled bool `json:"scheduled"`
+		Unlisted  bool `json:"unlisted"`
+		Protected bool `json:"protected"`
+	}
+
+	posts := make([]adminPost, 0, len(filtered))
+	for _, v := range filtered {
+		posts = append(posts, adminPost{
+			PostWithID: v,
+			Scheduled:  v.Published && scheduled(v.Post),
+			Unlisted:   accesses[v.ID].Unlisted,
+			Protected:  accesses[v.ID].protected(),
+		})
+	}
+
+	vars["posts"] = posts
 
 	return p.Render.Page(w, r, assets, "template/content/bloglist_edit.tmpl", p.FuncMap(), vars)
 }
 
 func (p *Plugin) postAdminCreate(w http.ResponseWriter, r *http.Request) (err error) {
-	vars := make(map[string]interface{})
-	vars["title"] = "New post"
-	vars["token"] = p.Site.SetCSRF(r)
-
-	return p.Render.Page(w, r, assets, "template/content/post_create.tmpl", p.FuncMap(), vars)
+	return p.postForm(w, r, "template/content/post_create.tmpl", "", ambient.Post{}, "", nil)
 }
 
 func (p *Plugin) postAdminStore(w http.ResponseWriter, r *http.Request) (err error) {
@@ -48,15 +201,10 @@ func (p *Plugin) postAdminStore(w http.ResponseWriter, r *http.Request) (err err
 
 	var post ambient.Post
 	post.Title = r.FormValue("title")
-	post.URL = r.FormValue("slug")
 	post.Canonical = r.FormValue("canonical_url")
 	post.Created = now
 	post.Updated = now
-	pubDate := r.FormValue("published_date")
-	if pubDate == "" {
-		pubDate = now.Format("2006-01-02")
-	}
-	ts, err := time.Parse("2006-01-02", pubDate)
+	ts, err := publishDate(r.FormValue("published_date"), now)
 	if err != nil {
 		return p.Mux.StatusError(http.StatusInternalServerError, err)
 	}
@@ -66,8 +214,92 @@ func (p *Plugin) postAdminStore(w http.ResponseWriter, r *http.Request) (err err
 	post.Page = r.FormValue("is_page") == "on"
 	post.Published = r.FormValue("publish") == "on"
 
+	slug, msg, err := p.checkSlug(ID, strings.TrimSpace(r.FormValue("slug")), post.Title)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+	post.URL = slug
+	formErrors := make([]string, 0)
+	if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	seriesName, seriesOrder, msg := seriesFormValues(r)
+	if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	lang, msg, err := p.languageFormValues(r, ID)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	social, msg := socialFormValues(r)
+	if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	if len(formErrors) > 0 {
+		return p.postForm(w, r, "template/content/post_create.tmpl", "", post, r.FormValue("published_date"), formErrors)
+	}
+
+	access, err := accessFormValues(r, postAccess{})
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	// Save the access settings first so the post is saved hidden if needed.
+	err = p.writePostAccess(ID, access, post.Published)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
 	// Save to storage.
-	err = p.Site.SavePost(ID, post)
+	err = p.savePost(ID, post)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.recordSlugChange(ID, "", post.URL)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setCommentsClosed(ID, r.FormValue("comments_closed") == "on")
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostSeries(ID, seriesName, seriesOrder)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostTOC(ID, r.FormValue("toc") == "on")
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostLanguage(ID, lang)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostSocial(ID, social)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	username, _ := p.Site.AuthenticatedUser(r)
+	err = p.setPostAuthor(ID, username)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.deleteDraft(draftKey("", username))
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.recordRevision(ID, post, username, false)
 	if err != nil {
 		return p.Site.Error(err)
 	}
@@ -77,35 +309,28 @@ func (p *Plugin) postAdminStore(w http.ResponseWriter, r *http.Request) (err err
 }
 
 func (p *Plugin) postAdminEdit(w http.ResponseWriter, r *http.Request) (err error) {
-	vars := make(map[string]interface{})
-	vars["pagetitle"] = "Edit post"
-	vars["title"] = "Edit post"
-	vars["token"] = p.Site.SetCSRF(r)
-
 	ID := p.Mux.Param(r, "id")
 
-	post, err := p.Site.PostByID(ID)
+	if err := p.authorizePost(r, ID); err != nil {
+		return err
+	}
+
+	post, err := p.postByID(ID)
 	if err != nil {
 		return p.Site.Error(err)
 	}
 
-	vars["id"] = ID
-	vars["ptitle"] = post.Title
-	vars["url"] = post.URL
-	vars["canonical"] = post.Canonical
-	vars["timestamp"] = post.Timestamp
-	vars["body"] = post.Content
-	vars["tags"] = post.Tags.String()
-	vars["page"] = post.Page
-	vars["published"] = post.Published
-
-	return p.Render.Page(w, r, assets, "template/content/post_edit.tmpl", p.FuncMap(), vars)
+	return p.postForm(w, r, "template/content/post_edit.tmpl", ID, post, post.Timestamp.Format("2006-01-02"), nil)
 }
 
 func (p *Plugin) postAdminUpdate(w http.ResponseWriter, r *http.Request) (err error) {
 	ID := p.Mux.Param(r, "id")
 
-	post, err := p.Site.PostByID(ID)
+	if err := p.authorizePost(r, ID); err != nil {
+		return err
+	}
+
+	post, err := p.postByID(ID)
 	if err != nil {
 		return p.Site.Error(err)
 	}
@@ -119,14 +344,19 @@ func (p *Plugin) postAdminUpdate(w http.ResponseWriter, r *http.Request) (err er
 		return p.Mux.StatusError(http.StatusBadRequest, nil)
 	}
 
+	// Keep the content from before revisions were tracked.
+	err = p.recordRevision(ID, post, "", true)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
 	now := time.Now()
+	oldSlug := post.URL
 
 	post.Title = r.FormValue("title")
-	post.URL = r.FormValue("slug")
 	post.Canonical = r.FormValue("canonical_url")
 	post.Updated = now
-	pubDate := r.FormValue("published_date")
-	ts, err := time.Parse("2006-01-02", pubDate)
+	ts, err := publishDate(r.FormValue("published_date"), now)
 	if err != nil {
 		return p.Mux.StatusError(http.StatusInternalServerError, err)
 	}
@@ -136,8 +366,92 @@ func (p *Plugin) postAdminUpdate(w http.ResponseWriter, r *http.Request) (err er
 	post.Page = r.FormValue("is_page") == "on"
 	post.Published = r.FormValue("publish") == "on"
 
+	slug, msg, err := p.checkSlug(ID, strings.TrimSpace(r.FormValue("slug")), post.Title)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+	post.URL = slug
+	formErrors := make([]string, 0)
+	if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	seriesName, seriesOrder, msg := seriesFormValues(r)
+	if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	lang, msg, err := p.languageFormValues(r, ID)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	social, msg := socialFormValues(r)
+	if len(msg) > 0 {
+		formErrors = append(formErrors, msg)
+	}
+	if len(formErrors) > 0 {
+		return p.postForm(w, r, "template/content/post_edit.tmpl", ID, post, r.FormValue("published_date"), formErrors)
+	}
+
+	current, err := p.postAccess(ID)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	access, err := accessFormValues(r, current)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	// Save the access settings first so the post is saved hidden if needed.
+	err = p.writePostAccess(ID, access, post.Published)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
 	// Save to storage.
-	err = p.Site.SavePost(ID, post)
+	err = p.savePost(ID, post)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.recordSlugChange(ID, oldSlug, post.URL)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setCommentsClosed(ID, r.FormValue("comments_closed") == "on")
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostSeries(ID, seriesName, seriesOrder)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostTOC(ID, r.FormValue("toc") == "on")
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostLanguage(ID, lang)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.setPostSocial(ID, social)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	username, _ := p.Site.AuthenticatedUser(r)
+	err = p.recordRevision(ID, post, username, false)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.deleteDraft(ID)
 	if err != nil {
 		return p.Site.Error(err)
 	}
@@ -146,15 +460,188 @@ func (p *Plugin) postAdminUpdate(w http.ResponseWriter, r *http.Request) (err er
 	return
 }
 
-func (p *Plugin) postAdminDestroy(w http.ResponseWriter, r *http.Request) (err error) {
-	ID := p.Mux.Param(r, "id")
+// postForm renders the form to create a post or edit the post with the ID.
+// The date is the value of the date field and the form errors are shown
+// above the form. When the page is first loaded, a notice is shown if there is
+// an autosaved draft that is newer than the post and the draft is loaded into
+// the form if it's requested in the query string.
+func (p *Plugin) postForm(w http.ResponseWriter, r *http.Request, page string, ID string, post ambient.Post, date string, formErrors []string) (err error) {
+	vars := make(map[string]interface{})
+	vars["draftsaved"] = ""
+	if r.Method == http.MethodGet {
+		username, _ := p.Site.AuthenticatedUser(r)
+		d, found, err := p.draft(draftKey(ID, username))
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		if found && d.Saved.After(post.Updated) {
+			if r.URL.Query().Get("draft") == "true" {
+				post = d.apply(post)
+				date = d.Date
+			} else {
+				vars["draftsaved"] = d.Saved
+			}
+		}
+	}
+
+	if len(ID) == 0 {
+		vars["title"] = "New post"
+	} else {
+		vars["pagetitle"] = "Edit post"
+		vars["title"] = "Edit post"
+	}
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["uploadtoken"] = p.setCSRFForPath(r, "/dashboard/uploads")
+	vars["previewtoken"] = p.setCSRFForPath(r, "/dashboard/preview")
+	vars["deletetoken"] = ""
+	if len(ID) == 0 {
+		vars["drafttoken"] = p.setCSRFForPath(r, "/dashboard/posts/new/draft")
+	} else {
+		vars["drafttoken"] = p.setCSRFForPath(r, "/dashboard/posts/"+ID+"/draft")
+		vars["deletetoken"] = p.setCSRFForPath(r, "/dashboard/posts/"+ID+"/delete")
+	}
+
+	if formErrors == nil {
+		formErrors = []string{}
+	}
+	vars["errors"] = formErrors
+
+	vars["id"] = ID
+	vars["ptitle"] = post.Title
+	vars["url"] = post.URL
+	vars["canonical"] = post.Canonical
+	vars["timestamp"] = date
+	vars["body"] = post.Content
+	vars["tags"] = post.Tags.String()
+	vars["page"] = post.Page
+	vars["published"] = post.Published
 
-	_, err = p.Site.PostByID(ID)
+	seriesNames, err := p.seriesNames()
 	if err != nil {
 		return p.Site.Error(err)
 	}
+	vars["serieslist"] = seriesNames
+	vars["series"] = r.FormValue("series")
+	vars["seriesorder"] = r.FormValue("series_order")
+	if r.Method == http.MethodGet && len(ID) > 0 {
+		part, err := p.postSeries(ID)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+		vars["series"] = part.Name
+		vars["seriesorder"] = ""
+		if part.Order > 0 {
+			vars["seriesorder"] = strconv.Itoa(part.Order)
+		}
+	}
+
+	vars["commentsclosed"] = r.FormValue("comments_closed") == "on"
+	vars["toc"] = r.FormValue("toc") == "on"
+	if r.Method == http.MethodGet && len(ID) > 0 {
+		vars["commentsclosed"], err = p.commentsClosed(ID)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		vars["toc"], err = p.postTOC(ID)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+	}
+
+	vars["sitelang"] = p.siteLanguage()
+	vars["lang"] = r.FormValue("lang")
+	vars["translationof"] = r.FormValue("translation_of")
+	vars["translations"] = []translation{}
+	if len(ID) > 0 {
+		postsAndPages, err := p.postsAndPages(false)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		vars["translations"], err = p.translations(ID, postsAndPages)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		if r.Method == http.MethodGet {
+			pl, err := p.postLanguage(ID)
+			if err != nil {
+				return p.Site.Error(err)
+			}
+
+			vars["lang"] = pl.Lang
+			for _, v := range postsAndPages {
+				if len(pl.Group) > 0 && v.ID == pl.Group {
+					vars["translationof"] = v.URL
+				}
+			}
+		}
+	}
+
+	vars["summary"] = r.FormValue("summary")
+	vars["socialimage"] = r.FormValue("social_image")
+	if r.Method == http.MethodGet && len(ID) > 0 {
+		social, err := p.postSocial(ID)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		vars["summary"] = social.Summary
+		vars["socialimage"] = social.Image
+	}
+
+	access := postAccess{}
+	if len(ID) > 0 {
+		access, err = p.postAccess(ID)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+	}
+	vars["unlisted"] = r.FormValue("unlisted") == "on"
+	if r.Method == http.MethodGet {
+		vars["unlisted"] = access.Unlisted
+	}
+	vars["protected"] = access.protected()
+
+	// Share a link that lets people who aren't logged in preview the post.
+	vars["previewurl"] = ""
+	if len(ID) > 0 {
+		siteURL, err := p.Site.FullURL()
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		key, err := p.secretKey()
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		token := previewToken(key, ID, time.Now().Add(previewLifetime))
+		vars["previewurl"] = siteURL + "/" + post.URL + "?preview=" + token
+	}
+
+	return p.Render.Page(w, r, assets, page, p.FuncMap(), vars)
+}
+
+func (p *Plugin) postAdminDestroy(w http.ResponseWriter, r *http.Request) (err error) {
+	ID := p.Mux.Param(r, "id")
+
+	if err := p.authorizePost(r, ID); err != nil {
+		return err
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
 
-	err = p.Site.DeletePostByID(ID)
+	username, _ := p.Site.AuthenticatedUser(r)
+	err = p.trashPost(ID, username)
 	if err != nil {
 		return p.Site.Error(err)
 	}
@@ -0,0 +1,157 @@
+package bearblog
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (p *Plugin) postAdminRevisions(w http.ResponseWriter, r *http.Request) (err error) {
+	ID := p.Mux.Param(r, "id")
+
+	if err := p.authorizePost(r, ID); err != nil {
+		return err
+	}
+
+	post, err := p.postByID(ID)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	revs, err := p.revisions(ID)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Revisions"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["id"] = ID
+	vars["ptitle"] = post.Title
+
+	// Show the newest revisions first.
+	newestFirst := make([]revision, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		newestFirst = append(newestFirst, revs[i])
+	}
+	vars["revisions"] = newestFirst
+	vars["from"] = 0
+	vars["to"] = 0
+	vars["diff"] = nil
+
+	if len(revs) < 2 {
+		return p.Render.Page(w, r, assets, "template/content/revisions.tmpl", p.FuncMap(), vars)
+	}
+
+	// Compare the two newest revisions by default.
+	from := revs[len(revs)-2].Number
+	to := revs[len(revs)-1].Number
+	if s := r.URL.Query().Get("from"); len(s) > 0 {
+		from, err = strconv.Atoi(s)
+		if err != nil {
+			return p.Mux.StatusError(http.StatusBadRequest, err)
+		}
+	}
+	if s := r.URL.Query().Get("to"); len(s) > 0 {
+		to, err = strconv.Atoi(s)
+		if err != nil {
+			return p.Mux.StatusError(http.StatusBadRequest, err)
+		}
+	}
+
+	fromRev, found, err := p.revision(ID, from)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !found {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	toRev, found, err := p.revision(ID, to)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !found {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	vars["from"] = from
+	vars["to"] = to
+	vars["diff"] = lineDiff(revisionText(fromRev), revisionText(toRev))
+
+	return p.Render.Page(w, r, assets, "template/content/revisions.tmpl", p.FuncMap(), vars)
+}
+
+func (p *Plugin) postAdminRevisionRestore(w http.ResponseWriter, r *http.Request) (err error) {
+	ID := p.Mux.Param(r, "id")
+
+	if err := p.authorizePost(r, ID); err != nil {
+		return err
+	}
+
+	post, err := p.postByID(ID)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	number, err := strconv.Atoi(r.FormValue("number"))
+	if err != nil {
+		return p.Mux.StatusError(http.StatusBadRequest, err)
+	}
+
+	rev, found, err := p.revision(ID, number)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !found {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	oldSlug := post.URL
+
+	post.Title = rev.Title
+	// Keep the current slug if the old one is now used by another post.
+	slug, msg, err := p.checkSlug(ID, rev.URL, rev.Title)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if len(msg) == 0 {
+		post.URL = slug
+	}
+	post.Content = rev.Content
+	post.Tags = rev.Tags
+	post.Timestamp = rev.Timestamp
+	post.Updated = time.Now()
+
+	// Save to storage.
+	err = p.savePost(ID, post)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.recordSlugChange(ID, oldSlug, post.URL)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	username, _ := p.Site.AuthenticatedUser(r)
+	err = p.recordRevision(ID, post, username, false)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	p.Redirect(w, r, "/dashboard/posts/"+ID, http.StatusFound)
+	return
+}
+
+// revisionText returns the fields of a revision as text so they can be
+// compared with a line diff.
+func revisionText(rev revision) string {
+	return fmt.Sprintf("Title: %v\nPermalink: %v\nDate: %v\nTags: %v\n\n%v",
+		rev.Title, rev.URL, rev.Timestamp.Format("2006-01-02"), rev.Tags.String(), rev.Content)
+}
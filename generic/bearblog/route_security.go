@@ -0,0 +1,47 @@
+package bearblog
+
+import (
+	"net/http"
+)
+
+func (p *Plugin) securityIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
+	events, err := p.auditEvents()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Security"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["lockouts"] = p.loginLimiter.lockouts()
+	vars["events"] = events
+
+	return p.Render.Page(w, r, assets, "template/content/security.tmpl", p.FuncMap(), vars)
+}
+
+func (p *Plugin) securityUnlock(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	key := r.FormValue("key")
+	if p.loginLimiter.unlock(key) {
+		username, _ := p.Site.AuthenticatedUser(r)
+		p.audit(r, auditUnlock, username, key)
+	}
+
+	p.Redirect(w, r, "/dashboard/security", http.StatusFound)
+	return
+}
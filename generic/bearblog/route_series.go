@@ -0,0 +1,106 @@
+package bearblog
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ambientkit/ambient"
+)
+
+// seriesNav is the box on a post that shows its place in a series.
+type seriesNav struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Part      int    `json:"part"`
+	Total     int    `json:"total"`
+	PrevTitle string `json:"prevtitle"`
+	PrevURL   string `json:"prevurl"`
+	NextTitle string `json:"nexttitle"`
+	NextURL   string `json:"nexturl"`
+}
+
+// renderSeriesIndex lists the published posts in a series in order.
+func (p *Plugin) renderSeriesIndex(w http.ResponseWriter, r *http.Request, slug string) (err error) {
+	g, found, err := p.seriesGroup(slug)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !found {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	vars := make(map[string]interface{})
+	vars["pagetitle"] = g.name
+	vars["canonical"] = ""
+	vars["heading"] = g.name
+	vars["posts"] = g.posts
+
+	return p.Render.Page(w, r, assets, "template/content/series.tmpl", p.FuncMap(), vars)
+}
+
+// seriesNav returns the place of a published post in its series. False is
+// returned if the post isn't in a series.
+func (p *Plugin) seriesNav(post ambient.PostWithID) (seriesNav, bool, error) {
+	part, err := p.postSeries(post.ID)
+	if err != nil || len(part.Name) == 0 {
+		return seriesNav{}, false, err
+	}
+
+	g, found, err := p.seriesGroup(slugify(part.Name))
+	if err != nil || !found {
+		return seriesNav{}, false, err
+	}
+
+	for i, v := range g.posts {
+		if v.ID != post.ID {
+			continue
+		}
+
+		nav := seriesNav{
+			Name:  g.name,
+			URL:   seriesURL(g.slug),
+			Part:  i + 1,
+			Total: len(g.posts),
+		}
+		if i > 0 {
+			nav.PrevTitle = g.posts[i-1].Title
+			nav.PrevURL = "/" + g.posts[i-1].URL
+		}
+		if i < len(g.posts)-1 {
+			nav.NextTitle = g.posts[i+1].Title
+			nav.NextURL = "/" + g.posts[i+1].URL
+		}
+
+		return nav, true, nil
+	}
+
+	// Posts that aren't published yet don't have a place in the series.
+	return seriesNav{}, false, nil
+}
+
+// seriesFormValues returns the series name and order from the post form
+// with a message that explains why they can't be used.
+func seriesFormValues(r *http.Request) (string, int, string) {
+	name := strings.Join(strings.Fields(r.FormValue("series")), " ")
+	if len(name) == 0 {
+		return "", 0, ""
+	}
+
+	if len(name) > maxSeriesName {
+		return name, 0, "Series name must be 100 characters or less."
+	} else if len(slugify(name)) == 0 {
+		return name, 0, "Series name must contain letters or numbers."
+	}
+
+	s := strings.TrimSpace(r.FormValue("series_order"))
+	if len(s) == 0 {
+		return name, 0, ""
+	}
+
+	order, err := strconv.Atoi(s)
+	if err != nil || order < 1 {
+		return name, 0, "Part must be a number greater than zero."
+	}
+
+	return name, order, ""
+}
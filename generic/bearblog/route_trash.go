@@ -0,0 +1,144 @@
+package bearblog
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (p *Plugin) trashIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	cu, err := p.currentUser(r)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	all, err := p.trashedPosts()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	type trashView struct {
+		ID        string    `json:"id"`
+		Title     string    `json:"title"`
+		URL       string    `json:"url"`
+		Page      bool      `json:"page"`
+		Deleted   time.Time `json:"deleted"`
+		DeletedBy string    `json:"deletedby"`
+		Expires   string    `json:"expires"`
+	}
+
+	// Expired posts are purged by the next change to the trash so they are
+	// hidden until then.
+	retention := p.trashRetention()
+	expired := make(map[string]bool)
+	for _, ID := range expiredTrash(all, retention, time.Now()) {
+		expired[ID] = true
+	}
+
+	views := make([]trashView, 0, len(all))
+	for ID, v := range all {
+		if expired[ID] {
+			continue
+		}
+
+		// Authors only see their own posts.
+		allowed, err := p.canEditPost(cu, ID)
+		if err != nil {
+			return p.Site.Error(err)
+		} else if !allowed {
+			continue
+		}
+
+		expires := ""
+		if t := trashExpires(v.Deleted, retention); !t.IsZero() {
+			expires = t.Format(time.RFC3339)
+		}
+
+		views = append(views, trashView{
+			ID:        ID,
+			Title:     v.Post.Title,
+			URL:       v.Post.URL,
+			Page:      v.Post.Page,
+			Deleted:   v.Deleted,
+			DeletedBy: v.DeletedBy,
+			Expires:   expires,
+		})
+	}
+
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].Deleted.After(views[j].Deleted)
+	})
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Trash"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["posts"] = views
+	vars["retention"] = retention
+
+	return p.Render.Page(w, r, assets, "template/content/trash.tmpl", p.FuncMap(), vars)
+}
+
+func (p *Plugin) trashUpdate(w http.ResponseWriter, r *http.Request) (err error) {
+	cu, err := p.currentUser(r)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	// The buttons on each post send the action and ID together. The bulk
+	// action applies to all of the checked posts.
+	action := r.FormValue("action")
+	IDs := r.Form["ids"]
+	if single := strings.SplitN(r.FormValue("single"), ":", 2); len(single) == 2 {
+		action = single[0]
+		IDs = []string{single[1]}
+	}
+
+	if action != "restore" && action != "purge" {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	// Only posts in the trash can be restored or purged.
+	all, err := p.trashedPosts()
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !inTrash(all, IDs) {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	for _, ID := range IDs {
+		allowed, err := p.canEditPost(cu, ID)
+		if err != nil {
+			return p.Site.Error(err)
+		} else if !allowed {
+			return p.Mux.StatusError(http.StatusForbidden, nil)
+		}
+	}
+
+	for _, ID := range IDs {
+		if action == "restore" {
+			_, err = p.restorePost(ID)
+		} else {
+			_, err = p.purgePost(ID)
+		}
+		if err != nil {
+			return p.Site.Error(err)
+		}
+	}
+
+	err = p.purgeExpiredTrash()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	p.Redirect(w, r, "/dashboard/trash", http.StatusFound)
+	return
+}
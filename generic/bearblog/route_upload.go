@@ -0,0 +1,152 @@
+package bearblog
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/plugin/pkg/uuid"
+)
+
+// uploadResponse is returned to the editor after an image is uploaded.
+type uploadResponse struct {
+	URL      string `json:"url,omitempty"`
+	Markdown string `json:"markdown,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (p *Plugin) uploadIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	vars := make(map[string]interface{})
+	vars["title"] = "Uploads"
+	vars["token"] = p.Site.SetCSRF(r)
+
+	arr, err := p.uploads()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	type uploadView struct {
+		upload
+		URL      string `json:"url"`
+		Markdown string `json:"markdown"`
+	}
+
+	// Show the newest uploads first.
+	views := make([]uploadView, 0, len(arr))
+	for i := len(arr) - 1; i >= 0; i-- {
+		views = append(views, uploadView{
+			upload:   arr[i],
+			URL:      p.uploadURL(arr[i].Name),
+			Markdown: p.uploadMarkdown(arr[i]),
+		})
+	}
+	vars["uploads"] = views
+
+	return p.Render.Page(w, r, assets, "template/content/uploads.tmpl", p.FuncMap(), vars)
+}
+
+func (p *Plugin) uploadStore(w http.ResponseWriter, r *http.Request) (err error) {
+	// The editor uploads in the background and expects JSON back.
+	wantsJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+	fail := func(status int, err error, token string) error {
+		if !wantsJSON {
+			return p.Mux.StatusError(status, err)
+		}
+		return writeJSON(w, status, uploadResponse{Error: err.Error(), Token: token})
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err = r.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		return fail(http.StatusBadRequest, errors.New("image could not be read or is larger than 10 MB"), "")
+	}
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return fail(http.StatusBadRequest, errors.New("form has expired, reload the page"), "")
+	}
+
+	// Tokens can only be used once so send a new one for the next upload.
+	token := p.Site.SetCSRF(r)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return fail(http.StatusBadRequest, err, token)
+	}
+	defer file.Close()
+
+	ID, err := uuid.Generate()
+	if err != nil {
+		return p.Mux.StatusError(http.StatusInternalServerError, err)
+	}
+
+	u, files, err := processImage(file, ID)
+	if err == errUnsupportedImage || err == errImageTooLarge {
+		return fail(http.StatusBadRequest, err, token)
+	} else if err != nil {
+		return fail(http.StatusBadRequest, errors.New("image could not be read"), token)
+	}
+
+	u.Filename = path.Base(header.Filename)
+	u.Uploaded = time.Now()
+	u.Username, _ = p.Site.AuthenticatedUser(r)
+
+	for name, b := range files {
+		err = p.blobStore.Save(name, b)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+	}
+
+	err = p.addUpload(u)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	if wantsJSON {
+		return writeJSON(w, http.StatusCreated, uploadResponse{
+			URL:      p.uploadURL(u.Name),
+			Markdown: p.uploadMarkdown(u),
+			Token:    token,
+		})
+	}
+
+	p.Redirect(w, r, "/dashboard/uploads", http.StatusFound)
+	return
+}
+
+func (p *Plugin) uploadShow(w http.ResponseWriter, r *http.Request) (err error) {
+	name := p.Mux.Param(r, "name")
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if !strings.HasPrefix(contentType, "image/") {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	b, err := p.blobStore.Load(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return p.Mux.StatusError(http.StatusNotFound, nil)
+	} else if err != nil {
+		return p.Site.Error(err)
+	}
+
+	// Names are unique for each upload so the file never changes.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	_, err = w.Write(b)
+	return
+}
+
+// setCSRFForPath returns a CSRF token for a form on the current page that
+// submits to a different path. Tokens are stored for each path.
+func (p *Plugin) setCSRFForPath(r *http.Request, path string) string {
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = p.Path(path)
+	return p.Site.SetCSRF(r2)
+}
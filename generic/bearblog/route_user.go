@@ -0,0 +1,302 @@
+package bearblog
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/plugin/pkg/passhash"
+	"github.com/ambientkit/plugin/pkg/totp"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// minPasswordLength is the shortest password allowed for users.
+const minPasswordLength = 8
+
+// sessionPendingMFA is the session key for an MFA key that is waiting to be
+// confirmed with a token from an authenticator app.
+const sessionPendingMFA = "bearblog_pending_mfa"
+
+func (p *Plugin) userIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
+	return p.userList(w, r, nil)
+}
+
+func (p *Plugin) userStore(w http.ResponseWriter, r *http.Request) (err error) {
+	if err := p.requireRole(r, roleAdmin); err != nil {
+		return err
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	u := user{
+		Username: strings.ToLower(strings.TrimSpace(r.FormValue("username"))),
+		Name:     strings.TrimSpace(r.FormValue("name")),
+		Role:     r.FormValue("role"),
+		Created:  time.Now(),
+	}
+	password := r.FormValue("password")
+
+	formErrors := make([]string, 0)
+	owner, err := p.ownerUsername()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+	_, found, err := p.user(u.Username)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+	if !usernamePattern.MatchString(u.Username) {
+		formErrors = append(formErrors, "Username must be 2 to 32 lowercase letters, numbers, periods, hyphens, or underscores.")
+	} else if found || u.Username == owner {
+		formErrors = append(formErrors, fmt.Sprintf("Username '%v' is already used.", u.Username))
+	}
+	if !validRole(u.Role) {
+		formErrors = append(formErrors, "Role is not valid.")
+	}
+	if len(password) < minPasswordLength {
+		formErrors = append(formErrors, fmt.Sprintf("Password must be at least %v characters.", minPasswordLength))
+	}
+	if len(formErrors) > 0 {
+		return p.userList(w, r, formErrors)
+	}
+
+	u.PasswordHash, err = passhash.HashString(password)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	err = p.saveUser(u)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	p.Redirect(w, r, "/dashboard/users", http.StatusFound)
+	return
+}
+
+// userList renders the list of users with a form to add a user.
+func (p *Plugin) userList(w http.ResponseWriter, r *http.Request, formErrors []string) error {
+	owner, err := p.ownerUsername()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	arr, err := p.users()
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	type userView struct {
+		Username string    `json:"username"`
+		Name     string    `json:"name"`
+		Role     string    `json:"role"`
+		MFA      bool      `json:"mfa"`
+		Created  time.Time `json:"created"`
+	}
+
+	views := make([]userView, 0, len(arr))
+	for _, v := range arr {
+		views = append(views, userView{
+			Username: v.Username,
+			Name:     v.Name,
+			Role:     v.Role,
+			MFA:      len(v.MFAKey) > 0,
+			Created:  v.Created,
+		})
+	}
+
+	if formErrors == nil {
+		formErrors = []string{}
+	}
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Users"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["owner"] = owner
+	vars["users"] = views
+	vars["roles"] = roles
+	vars["errors"] = formErrors
+
+	return p.Render.Page(w, r, assets, "template/content/users.tmpl", p.FuncMap(), vars)
+}
+
+func (p *Plugin) userEdit(w http.ResponseWriter, r *http.Request) (err error) {
+	u, cu, err := p.editableUser(r)
+	if err != nil {
+		return err
+	}
+
+	return p.userForm(w, r, u, cu, nil, "", "")
+}
+
+func (p *Plugin) userUpdate(w http.ResponseWriter, r *http.Request) (err error) {
+	u, cu, err := p.editableUser(r)
+	if err != nil {
+		return err
+	}
+
+	r.ParseForm()
+
+	// CSRF protection.
+	success := p.Site.CSRF(r, r.FormValue("token"))
+	if !success {
+		return p.Mux.StatusError(http.StatusBadRequest, nil)
+	}
+
+	self := cu.Username == u.Username
+	formErrors := make([]string, 0)
+
+	switch r.FormValue("action") {
+	case "delete":
+		if cu.Role != roleAdmin || self {
+			return p.Mux.StatusError(http.StatusForbidden, nil)
+		}
+
+		err = p.deleteUser(u.Username)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		p.Redirect(w, r, "/dashboard/users", http.StatusFound)
+		return
+	case "mfa_setup":
+		siteTitle, err := p.Site.Title()
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		URI, secret, err := totp.GenerateURL(u.Username, siteTitle)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		png, err := qrcode.Encode(URI, qrcode.Medium, 400)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		err = p.Site.SetSessionValue(r, sessionPendingMFA, u.Username+":"+secret)
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		return p.userForm(w, r, u, cu, nil, secret, base64.StdEncoding.EncodeToString(png))
+	case "mfa_confirm":
+		pending := strings.SplitN(p.Site.SessionValue(r, sessionPendingMFA), ":", 2)
+		if len(pending) != 2 || pending[0] != u.Username {
+			formErrors = append(formErrors, "MFA setup has expired, please start again.")
+		} else if !validMFA(r.FormValue("mfa"), pending[1]) {
+			formErrors = append(formErrors, "MFA token is not correct, please start again.")
+		} else {
+			u.MFAKey = pending[1]
+		}
+		p.Site.DeleteSessionValue(r, sessionPendingMFA)
+	case "mfa_disable":
+		u.MFAKey = ""
+	default:
+		u.Name = strings.TrimSpace(r.FormValue("name"))
+
+		// Admins can't change their own role so there is always an admin.
+		if cu.Role == roleAdmin && !self {
+			if role := r.FormValue("role"); validRole(role) {
+				u.Role = role
+			} else {
+				formErrors = append(formErrors, "Role is not valid.")
+			}
+		}
+
+		if password := r.FormValue("password"); len(password) > 0 {
+			if len(password) < minPasswordLength {
+				formErrors = append(formErrors, fmt.Sprintf("Password must be at least %v characters.", minPasswordLength))
+			} else {
+				u.PasswordHash, err = passhash.HashString(password)
+				if err != nil {
+					return p.Site.Error(err)
+				}
+			}
+		}
+	}
+
+	if len(formErrors) > 0 {
+		return p.userForm(w, r, u, cu, formErrors, "", "")
+	}
+
+	err = p.saveUser(u)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	p.Redirect(w, r, "/dashboard/users/"+u.Username, http.StatusFound)
+	return
+}
+
+// editableUser returns the user from the URL if the authenticated user can
+// edit them. Admins can edit all users and other users can only edit
+// themselves.
+func (p *Plugin) editableUser(r *http.Request) (user, currentUser, error) {
+	cu, err := p.currentUser(r)
+	if err != nil {
+		return user{}, cu, p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	username := p.Mux.Param(r, "username")
+	if cu.Role != roleAdmin && cu.Username != username {
+		return user{}, cu, p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	u, found, err := p.user(username)
+	if err != nil {
+		return user{}, cu, p.Site.Error(err)
+	} else if !found {
+		return user{}, cu, p.Mux.StatusError(http.StatusNotFound, nil)
+	}
+
+	return u, cu, nil
+}
+
+// userForm renders the form to edit a user. The MFA secret and QR code are
+// only set while MFA is being set up.
+func (p *Plugin) userForm(w http.ResponseWriter, r *http.Request, u user, cu currentUser, formErrors []string, mfaSecret string, qrCode string) error {
+	if formErrors == nil {
+		formErrors = []string{}
+	}
+
+	vars := make(map[string]interface{})
+	vars["title"] = "Edit user"
+	vars["token"] = p.Site.SetCSRF(r)
+	vars["errors"] = formErrors
+	vars["username"] = u.Username
+	vars["name"] = u.Name
+	vars["role"] = u.Role
+	vars["roles"] = roles
+	vars["mfa"] = len(u.MFAKey) > 0
+	vars["mfasecret"] = mfaSecret
+	vars["qrcode"] = qrCode
+	vars["isadmin"] = cu.Role == roleAdmin
+	vars["self"] = cu.Username == u.Username
+
+	return p.Render.Page(w, r, assets, "template/content/user_edit.tmpl", p.FuncMap(), vars)
+}
+
+// validRole returns true if the role exists.
+func validRole(role string) bool {
+	for _, v := range roles {
+		if v == role {
+			return true
+		}
+	}
+
+	return false
+}
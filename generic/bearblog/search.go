@@ -0,0 +1,250 @@
+package bearblog
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ambientkit/ambient"
+)
+
+const (
+	// Field weights used when ranking search results.
+	weightTitle   = 3.0
+	weightTag     = 2.0
+	weightContent = 1.0
+
+	// snippetLength is the approximate number of characters in a snippet.
+	snippetLength = 160
+)
+
+// searchResult is a post that matched a search query.
+type searchResult struct {
+	ambient.PostWithID
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchDoc is a post that has been prepared for searching.
+type searchDoc struct {
+	post      ambient.PostWithID
+	plaintext string
+}
+
+// searchIndex is an in-memory inverted index of published posts and pages.
+type searchIndex struct {
+	mu      sync.RWMutex
+	valid   bool
+	expires time.Time
+	docs    map[string]searchDoc
+	terms   map[string]map[string]float64
+}
+
+// newSearchIndex returns an empty search index that will be built on first use.
+func newSearchIndex() *searchIndex {
+	return &searchIndex{}
+}
+
+// Invalidate marks the index as stale so it is rebuilt on the next search.
+func (si *searchIndex) Invalidate() {
+	si.mu.Lock()
+	si.valid = false
+	si.mu.Unlock()
+}
+
+// Stale returns true if the index needs to be rebuilt.
+func (si *searchIndex) Stale() bool {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	return !si.valid || (!si.expires.IsZero() && !time.Now().Before(si.expires))
+}
+
+// Build replaces the contents of the index with the posts. If expires is not
+// zero, the index becomes stale at that time.
+func (si *searchIndex) Build(posts []ambient.PostWithID, expires time.Time) {
+	docs := make(map[string]searchDoc, len(posts))
+	terms := make(map[string]map[string]float64)
+
+	add := func(ID string, s string, weight float64) {
+		for _, term := range tokenize(s) {
+			if _, ok := terms[term]; !ok {
+				terms[term] = make(map[string]float64)
+			}
+			terms[term][ID] += weight
+		}
+	}
+
+	for _, v := range posts {
+		doc := searchDoc{
+			post:      v,
+			plaintext: plaintext(v.Content),
+		}
+		docs[v.ID] = doc
+
+		add(v.ID, v.Title, weightTitle)
+		add(v.ID, doc.plaintext, weightContent)
+		for _, tag := range v.Tags {
+			add(v.ID, tag.Name, weightTag)
+		}
+	}
+
+	si.mu.Lock()
+	si.docs = docs
+	si.terms = terms
+	si.expires = expires
+	si.valid = true
+	si.mu.Unlock()
+}
+
+// Search returns the posts that contain every term in the query, ordered by
+// relevance and then by date.
+func (si *searchIndex) Search(query string) []searchResult {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	results := make([]searchResult, 0)
+
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 {
+		return results
+	}
+
+	scores := make(map[string]float64)
+	for i, term := range queryTerms {
+		matches := si.terms[term]
+		if i == 0 {
+			for ID, weight := range matches {
+				scores[ID] = weight
+			}
+			continue
+		}
+
+		// Only keep posts that match all the terms.
+		for ID := range scores {
+			weight, ok := matches[ID]
+			if !ok {
+				delete(scores, ID)
+				continue
+			}
+			scores[ID] += weight
+		}
+	}
+
+	for ID, score := range scores {
+		doc := si.docs[ID]
+		results = append(results, searchResult{
+			PostWithID: doc.post,
+			Score:      score,
+			Snippet:    snippet(doc.plaintext, queryTerms),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Timestamp.After(results[j].Timestamp)
+	})
+
+	return results
+}
+
+// search returns the published posts and pages that match the query. The
+// index is rebuilt first if the posts have changed or a scheduled post has
+// been released.
+func (p *Plugin) search(query string) ([]searchResult, error) {
+	if p.searchIndex.Stale() {
+		postsAndPages, err := p.postsAndPages(true)
+		if err != nil {
+			return nil, err
+		}
+
+		accesses, err := p.postAccesses()
+		if err != nil {
+			return nil, err
+		}
+
+		live := make([]ambient.PostWithID, 0)
+		for _, v := range postsAndPages {
+			// Don't show the content of protected posts in snippets.
+			if !scheduled(v.Post) && !accesses[v.ID].Unlisted && !accesses[v.ID].protected() {
+				live = append(live, v)
+			}
+		}
+
+		p.searchIndex.Build(live, nextScheduled(postsAndPages))
+	}
+
+	return p.searchIndex.Search(query), nil
+}
+
+// tokenize returns the lowercase words in a string.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// snippet returns an HTML escaped excerpt of the text around the first
+// matching term with every matching word wrapped in a <mark> element.
+func snippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return ""
+	}
+
+	isMatch := func(word string) bool {
+		for _, w := range tokenize(word) {
+			for _, term := range terms {
+				if w == term {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Find the first word that matches so the snippet can start just before it.
+	first := 0
+	for i, word := range words {
+		if isMatch(word) {
+			first = i
+			break
+		}
+	}
+
+	start := first - 5
+	if start < 0 {
+		start = 0
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("… ")
+	}
+
+	length := 0
+	end := start
+	for ; end < len(words) && length < snippetLength; end++ {
+		if end > start {
+			sb.WriteString(" ")
+		}
+		word := words[end]
+		if isMatch(word) {
+			sb.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			sb.WriteString(html.EscapeString(word))
+		}
+		length += len(word) + 1
+	}
+
+	if end < len(words) {
+		sb.WriteString(" …")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package bearblog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestSearchIndex(t *testing.T) {
+	now := time.Now()
+
+	si := newSearchIndex()
+	si.Build([]ambient.PostWithID{
+		{
+			ID: "1",
+			Post: ambient.Post{
+				Title:     "Hello World",
+				Content:   "A post about *Go* templates.",
+				Timestamp: now,
+			},
+		},
+		{
+			ID: "2",
+			Post: ambient.Post{
+				Title:     "Other post",
+				Content:   "Nothing to see here except the world.",
+				Timestamp: now.Add(-time.Hour),
+				Tags:      ambient.TagList{{Name: "go"}},
+			},
+		},
+	}, time.Time{})
+
+	results := si.Search("WORLD")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", len(results))
+	}
+	if results[0].ID != "1" {
+		t.Errorf("expected title match to rank first, got %v", results[0].ID)
+	}
+
+	results = si.Search("go see")
+	if len(results) != 1 || results[0].ID != "2" {
+		t.Fatalf("expected only post 2, got %v", results)
+	}
+	if results[0].Score != weightTag+weightContent {
+		t.Errorf("expected tag and content weights, got %v", results[0].Score)
+	}
+
+	results = si.Search("templates hello")
+	if len(results) != 1 || results[0].ID != "1" {
+		t.Fatalf("expected only post 1, got %v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>templates.</mark>") {
+		t.Errorf("expected highlighted snippet, got %v", results[0].Snippet)
+	}
+
+	if results := si.Search("missing"); len(results) != 0 {
+		t.Errorf("expected no results, got %v", len(results))
+	}
+}
+
+func TestSnippetEscapesHTML(t *testing.T) {
+	s := snippet("<b>bold</b> text", []string{"text"})
+	if s != "&lt;b&gt;bold&lt;/b&gt; <mark>text</mark>" {
+		t.Errorf("unexpected snippet: %v", s)
+	}
+}
@@ -0,0 +1,67 @@
+package bearblog
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// secretKey returns the key used to sign tokens. A random key is generated
+// and stored the first time it's needed.
+func (p *Plugin) secretKey() ([]byte, error) {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	var s string
+	err := p.loadData(dataSecret, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s) == 0 {
+		b := make([]byte, 32)
+		_, err = rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+
+		s = base64.StdEncoding.EncodeToString(b)
+		err = p.saveData(dataSecret, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// sign returns a signature of the parts that can't be created without the
+// secret key.
+func (p *Plugin) sign(parts ...string) (string, error) {
+	key, err := p.secretKey()
+	if err != nil {
+		return "", err
+	}
+
+	return signWithKey(key, parts...), nil
+}
+
+// validSignature returns true if the signature matches the parts.
+func (p *Plugin) validSignature(signature string, parts ...string) (bool, error) {
+	key, err := p.secretKey()
+	if err != nil {
+		return false, err
+	}
+
+	return hmac.Equal([]byte(signature), []byte(signWithKey(key, parts...))), nil
+}
+
+// signWithKey returns an HMAC of the parts. The parts are joined with a
+// separator so different parts can't produce the same message.
+func signWithKey(key []byte, parts ...string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join(parts, "\x00")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
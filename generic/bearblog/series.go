@@ -0,0 +1,237 @@
+package bearblog
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ambientkit/ambient"
+	"github.com/ambientkit/plugin/generic/rssfeed"
+	"github.com/ambientkit/plugin/generic/sitemap"
+)
+
+// maxSeriesName is the longest series name allowed.
+const maxSeriesName = 100
+
+// seriesPart is the series a post belongs to and its position in the series.
+type seriesPart struct {
+	Name  string `json:"name"`
+	Order int    `json:"order"`
+}
+
+// seriesGroup is the posts in a series in reading order.
+type seriesGroup struct {
+	name  string
+	slug  string
+	posts []ambient.PostWithID
+}
+
+// seriesURL returns the path to the page that lists a series.
+func seriesURL(slug string) string {
+	return "/blog/series/" + slug
+}
+
+// seriesParts returns the series of each post mapped to the post ID.
+func (p *Plugin) seriesParts() (map[string]seriesPart, error) {
+	all := make(map[string]seriesPart)
+	err := p.loadData(dataSeries, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// postSeries returns the series of a post. An empty name is returned if the
+// post isn't in a series.
+func (p *Plugin) postSeries(ID string) (seriesPart, error) {
+	all, err := p.seriesParts()
+	if err != nil {
+		return seriesPart{}, err
+	}
+
+	return all[ID], nil
+}
+
+// setPostSeries adds a post to a series. If the order is less than one, the
+// post is added to the end of the series. An empty name removes the post
+// from its series.
+func (p *Plugin) setPostSeries(ID string, name string, order int) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]seriesPart)
+	err := p.loadData(dataSeries, &all)
+	if err != nil {
+		return err
+	}
+
+	if len(name) == 0 {
+		if _, ok := all[ID]; !ok {
+			return nil
+		}
+		delete(all, ID)
+		return p.saveData(dataSeries, all)
+	}
+
+	if order < 1 {
+		order = 1
+		slug := slugify(name)
+		for postID, v := range all {
+			if postID != ID && slugify(v.Name) == slug && v.Order >= order {
+				order = v.Order + 1
+			}
+		}
+	}
+
+	all[ID] = seriesPart{Name: name, Order: order}
+
+	return p.saveData(dataSeries, all)
+}
+
+// seriesNames returns the names of all series, including series without any
+// published posts, sorted by name.
+func (p *Plugin) seriesNames() ([]string, error) {
+	all, err := p.seriesParts()
+	if err != nil {
+		return nil, err
+	}
+
+	slugs := make(map[string]bool)
+	arr := make([]string, 0)
+	for _, v := range all {
+		slug := slugify(v.Name)
+		if !slugs[slug] {
+			slugs[slug] = true
+			arr = append(arr, v.Name)
+		}
+	}
+	sort.Strings(arr)
+
+	return arr, nil
+}
+
+// seriesGroups returns the published series with their published posts in
+// order, sorted by name.
+func (p *Plugin) seriesGroups() ([]seriesGroup, error) {
+	all, err := p.seriesParts()
+	if err != nil {
+		return nil, err
+	}
+
+	postsAndPages, err := p.livePostsAndPages()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*seriesGroup)
+	for _, v := range postsAndPages {
+		part, ok := all[v.ID]
+		if !ok || v.Page {
+			continue
+		}
+
+		slug := slugify(part.Name)
+		g, ok := groups[slug]
+		if !ok {
+			g = &seriesGroup{name: part.Name, slug: slug}
+			groups[slug] = g
+		}
+		g.posts = append(g.posts, v)
+	}
+
+	arr := make([]seriesGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.SliceStable(g.posts, func(i, j int) bool {
+			a, b := all[g.posts[i].ID], all[g.posts[j].ID]
+			if a.Order != b.Order {
+				return a.Order < b.Order
+			}
+			return g.posts[i].Timestamp.Before(g.posts[j].Timestamp)
+		})
+
+		// Use the name from the first part in case the case or spacing is
+		// different between posts.
+		g.name = all[g.posts[0].ID].Name
+		arr = append(arr, *g)
+	}
+	sort.Slice(arr, func(i, j int) bool {
+		return strings.ToLower(arr[i].name) < strings.ToLower(arr[j].name)
+	})
+
+	return arr, nil
+}
+
+// seriesGroup returns a published series by its slug.
+func (p *Plugin) seriesGroup(slug string) (seriesGroup, bool, error) {
+	arr, err := p.seriesGroups()
+	if err != nil {
+		return seriesGroup{}, false, err
+	}
+
+	for _, g := range arr {
+		if g.slug == slug {
+			return g, true, nil
+		}
+	}
+
+	return seriesGroup{}, false, nil
+}
+
+// SitemapPages returns the archive pages of tags and months and the pages
+// that list each published series so they can be added to the sitemap plugin
+// with AddPageSource.
+func (p *Plugin) SitemapPages() ([]sitemap.Page, error) {
+	posts, err := p.livePostsAndPages()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := p.seriesGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(archivePages(posts), seriesPages(groups)...), nil
+}
+
+// seriesPages returns the pages that list each series for the sitemap. Each
+// page was last modified when its newest post was published.
+func seriesPages(groups []seriesGroup) []sitemap.Page {
+	pages := make([]sitemap.Page, 0, len(groups))
+	for _, g := range groups {
+		page := sitemap.Page{
+			Path: seriesURL(g.slug),
+		}
+		for _, v := range g.posts {
+			if v.Timestamp.After(page.LastModified) {
+				page.LastModified = v.Timestamp
+			}
+		}
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+// FeedCategories returns each published series as a category so posts can be
+// labeled with their series in the RSS feed plugin with AddCategorySource.
+func (p *Plugin) FeedCategories() ([]rssfeed.Category, error) {
+	arr, err := p.seriesGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]rssfeed.Category, 0, len(arr))
+	for _, g := range arr {
+		c := rssfeed.Category{
+			Name: g.name,
+			Path: seriesURL(g.slug),
+		}
+		for _, v := range g.posts {
+			c.PostURLs = append(c.PostURLs, v.URL)
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, nil
+}
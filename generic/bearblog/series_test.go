@@ -0,0 +1,65 @@
+package bearblog
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestSeriesFormValues(t *testing.T) {
+	tests := []struct {
+		series string
+		order  string
+		name   string
+		num    int
+		valid  bool
+	}{
+		{"", "3", "", 0, true},
+		{"  Go   Basics ", "", "Go Basics", 0, true},
+		{"Go Basics", "2", "Go Basics", 2, true},
+		{"Go Basics", "0", "Go Basics", 0, false},
+		{"Go Basics", "two", "Go Basics", 0, false},
+		{"!!!", "", "!!!", 0, false},
+		{strings.Repeat("a", maxSeriesName+1), "", strings.Repeat("a", maxSeriesName+1), 0, false},
+	}
+
+	for _, tt := range tests {
+		form := url.Values{"series": {tt.series}, "series_order": {tt.order}}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		name, num, msg := seriesFormValues(r)
+		if name != tt.name || num != tt.num || (len(msg) == 0) != tt.valid {
+			t.Errorf("seriesFormValues(%q, %q): got %q, %v, %q", tt.series, tt.order, name, num, msg)
+		}
+	}
+}
+
+func TestSeriesPages(t *testing.T) {
+	first := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2021, 2, 10, 0, 0, 0, 0, time.UTC)
+	groups := []seriesGroup{
+		{name: "Go Basics", slug: "go-basics", posts: []ambient.PostWithID{
+			{ID: "2", Post: ambient.Post{Timestamp: second}},
+			{ID: "1", Post: ambient.Post{Timestamp: first}},
+		}},
+		{name: "Web", slug: "web", posts: []ambient.PostWithID{
+			{ID: "3", Post: ambient.Post{Timestamp: first}},
+		}},
+	}
+
+	pages := seriesPages(groups)
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %v", pages)
+	}
+	if pages[0].Path != "/blog/series/go-basics" || !pages[0].LastModified.Equal(second) {
+		t.Errorf("expected the newest post of the first series, got %v", pages[0])
+	}
+	if pages[1].Path != "/blog/series/web" || !pages[1].LastModified.Equal(first) {
+		t.Errorf("expected the second series, got %v", pages[1])
+	}
+}
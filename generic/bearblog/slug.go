@@ -0,0 +1,189 @@
+package bearblog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxSlugLength is the longest slug generated from a title.
+const maxSlugLength = 80
+
+var (
+	// slugPattern is lowercase letters and numbers separated by single hyphens
+	// or underscores.
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:[-_][a-z0-9]+)*$`)
+
+	// reservedSlugs are used by the routes of the plugin so posts can't use
+	// them.
+	reservedSlugs = map[string]bool{
+		"api":       true,
+		"blog":      true,
+		"dashboard": true,
+		"login":     true,
+		"micropub":  true,
+		"plugins":   true,
+		"uploads":   true,
+	}
+)
+
+// slugify returns a slug made from a title. Accents are removed from letters
+// and everything else that isn't a letter or number becomes a hyphen.
+func slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(strings.ToLower(title)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Skip the accents split from letters.
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		default:
+			hyphen = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+
+	return slug
+}
+
+// validateSlug returns a message that explains why a slug can't be used. An
+// empty message is returned if the slug is valid.
+func validateSlug(slug string) string {
+	if len(slug) == 0 {
+		return "Permalink is required."
+	} else if !slugPattern.MatchString(slug) {
+		return "Permalink can only contain lowercase letters, numbers, and single hyphens or underscores between them."
+	} else if reservedSlugs[slug] {
+		return fmt.Sprintf("Permalink '%v' is reserved, please choose another.", slug)
+	}
+
+	return ""
+}
+
+// slugTaken returns true if a post other than the one with the ID uses the
+// slug.
+func (p *Plugin) slugTaken(ID string, slug string) (bool, error) {
+	postsAndPages, err := p.postsAndPages(false)
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range postsAndPages {
+		if v.URL == slug && v.ID != ID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkSlug validates the slug of a post that is about to be saved. If the
+// slug is empty, one is generated from the title and a number is added to the
+// end if it's already used. A message is returned if the slug can't be used.
+func (p *Plugin) checkSlug(ID string, slug string, title string) (string, string, error) {
+	if len(slug) > 0 {
+		if msg := validateSlug(slug); len(msg) > 0 {
+			return slug, msg, nil
+		}
+
+		taken, err := p.slugTaken(ID, slug)
+		if err != nil {
+			return slug, "", err
+		} else if taken {
+			return slug, fmt.Sprintf("Permalink '%v' is already used by another post.", slug), nil
+		}
+
+		return slug, "", nil
+	}
+
+	base := slugify(title)
+	if len(base) == 0 {
+		return "", "Permalink is required when the title doesn't contain any letters or numbers.", nil
+	}
+
+	slug = base
+	for i := 2; ; i++ {
+		taken, err := p.slugTaken(ID, slug)
+		if err != nil {
+			return slug, "", err
+		}
+		if !taken && len(validateSlug(slug)) == 0 {
+			return slug, "", nil
+		}
+		slug = fmt.Sprintf("%v-%v", base, i)
+	}
+}
+
+// oldSlugs returns the previous slugs of posts mapped to the post ID.
+func (p *Plugin) oldSlugs() (map[string]string, error) {
+	all := make(map[string]string)
+	err := p.loadData(dataSlugs, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// recordSlugChange keeps the previous slug of a post so links to it can be
+// redirected. The new slug is removed in case it was the previous slug of any
+// post since it now belongs to this one.
+func (p *Plugin) recordSlugChange(ID string, oldSlug string, newSlug string) error {
+	if oldSlug == newSlug {
+		return nil
+	}
+
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]string)
+	err := p.loadData(dataSlugs, &all)
+	if err != nil {
+		return err
+	}
+
+	if len(oldSlug) > 0 {
+		all[oldSlug] = ID
+	}
+	delete(all, newSlug)
+
+	return p.saveData(dataSlugs, all)
+}
+
+// deleteSlugs removes the previous slugs of a post.
+func (p *Plugin) deleteSlugs(ID string) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]string)
+	err := p.loadData(dataSlugs, &all)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for slug, postID := range all {
+		if postID == ID {
+			delete(all, slug)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return p.saveData(dataSlugs, all)
+}
@@ -0,0 +1,34 @@
+package bearblog
+
+import (
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Why I Like Sunny Days":    "why-i-like-sunny-days",
+		"  Café & Crème Brûlée!! ": "cafe-creme-brulee",
+		"Go 1.18: Generics":        "go-1-18-generics",
+		"日本語":                      "",
+	}
+
+	for title, expected := range tests {
+		if got := slugify(title); got != expected {
+			t.Errorf("slugify(%q): expected %q, got %q", title, expected, got)
+		}
+	}
+}
+
+func TestValidateSlug(t *testing.T) {
+	for _, slug := range []string{"hello", "hello-world", "post_2", "2021"} {
+		if msg := validateSlug(slug); len(msg) > 0 {
+			t.Errorf("expected %q to be valid, got: %v", slug, msg)
+		}
+	}
+
+	for _, slug := range []string{"", "Hello", "hello world", "-hello", "hello--world", "a/b", "blog", "dashboard", "login"} {
+		if msg := validateSlug(slug); len(msg) == 0 {
+			t.Errorf("expected %q to be invalid", slug)
+		}
+	}
+}
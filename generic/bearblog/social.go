@@ -0,0 +1,248 @@
+package bearblog
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ambientkit/ambient"
+)
+
+// maxSummaryLength is the longest summary in characters. Sites that show
+// shared links cut off longer descriptions anyway.
+const maxSummaryLength = 300
+
+// postSocial is the summary and image shown when a post is shared.
+type postSocial struct {
+	Summary string `json:"summary,omitempty"`
+	// Image is a full URL or a path on the site.
+	Image string `json:"image,omitempty"`
+}
+
+// socialMeta is the OpenGraph and Twitter Card metadata of a post.
+type socialMeta struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Type        string `json:"type"`
+	Image       string `json:"image"`
+	SiteName    string `json:"sitename"`
+	Card        string `json:"card"`
+}
+
+// postSocials returns the summary and image of posts mapped to the post ID.
+// Posts that don't set either are not included.
+func (p *Plugin) postSocials() (map[string]postSocial, error) {
+	all := make(map[string]postSocial)
+	err := p.loadData(dataSocial, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// postSocial returns the summary and image of a post.
+func (p *Plugin) postSocial(ID string) (postSocial, error) {
+	all, err := p.postSocials()
+	if err != nil {
+		return postSocial{}, err
+	}
+
+	return all[ID], nil
+}
+
+// setPostSocial sets the summary and image of a post. An empty value removes
+// the post.
+func (p *Plugin) setPostSocial(ID string, s postSocial) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]postSocial)
+	err := p.loadData(dataSocial, &all)
+	if err != nil {
+		return err
+	}
+
+	if s == (postSocial{}) {
+		if _, ok := all[ID]; !ok {
+			return nil
+		}
+		delete(all, ID)
+	} else {
+		all[ID] = s
+	}
+
+	return p.saveData(dataSocial, all)
+}
+
+// socialFormValues returns the summary and image from the post form. A
+// message is returned if the values can't be used.
+func socialFormValues(r *http.Request) (postSocial, string) {
+	s := postSocial{
+		Summary: strings.TrimSpace(r.FormValue("summary")),
+		Image:   strings.TrimSpace(r.FormValue("social_image")),
+	}
+
+	if utf8.RuneCountInString(s.Summary) > maxSummaryLength {
+		return s, "Summary should be 300 characters or less."
+	} else if !validImageURL(s.Image) {
+		return s, "Social image should be a URL that starts with 'https://' or '/'."
+	}
+
+	return s, ""
+}
+
+// validImageURL returns true if the image is empty, a full http or https URL,
+// or a path on the site.
+func validImageURL(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	if len(u.Scheme) == 0 {
+		return len(u.Host) == 0 && strings.HasPrefix(u.Path, "/")
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
+
+// absoluteURL returns the URL with the site URL in front if it's a path.
+func absoluteURL(siteURL string, s string) string {
+	if strings.HasPrefix(s, "/") {
+		return siteURL + s
+	}
+
+	return s
+}
+
+// socialVars sets the variables for the OpenGraph, Twitter Card, and JSON-LD
+// metadata of a post. The description is also used for the meta description.
+// Posts without their own summary or image use a blurb of the content and the
+// site image.
+func (p *Plugin) socialVars(vars map[string]interface{}, post ambient.PostWithID, author string) error {
+	social, err := p.postSocial(post.ID)
+	if err != nil {
+		return err
+	}
+
+	siteURL, err := p.Site.FullURL()
+	if err != nil {
+		return err
+	}
+
+	siteTitle, err := p.Site.Title()
+	if err != nil {
+		return err
+	}
+
+	description := social.Summary
+	if len(description) == 0 {
+		description = plaintextBlurb(post.Content)
+	}
+	if len(description) == 0 {
+		description, err = p.Site.PluginSettingString(Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	image := social.Image
+	if len(image) == 0 {
+		image, err = p.Site.PluginSettingString(SocialImage)
+		if err != nil {
+			return err
+		}
+	}
+
+	postURL := siteURL + "/" + post.URL
+	if len(post.Canonical) > 0 {
+		postURL = post.Canonical
+	}
+
+	meta := socialMeta{
+		Title:       post.Title,
+		Description: description,
+		URL:         postURL,
+		Type:        "article",
+		Image:       absoluteURL(siteURL, image),
+		SiteName:    siteTitle,
+		Card:        "summary",
+	}
+	if post.Page {
+		meta.Type = "website"
+	}
+	if len(meta.Image) > 0 {
+		meta.Card = "summary_large_image"
+	}
+
+	vars["pagedescription"] = description
+	vars["og"] = meta
+
+	// Pages aren't articles so they don't get structured data.
+	vars["jsonld"] = false
+	if !post.Page {
+		pl, err := p.postLanguage(post.ID)
+		if err != nil {
+			return err
+		}
+
+		lang := pl.Lang
+		if len(lang) == 0 {
+			lang = p.siteLanguage()
+		}
+
+		vars["jsonld"] = postJSONLD(post.Post, meta, author, lang)
+	}
+
+	return nil
+}
+
+// postJSONLD returns the schema.org BlogPosting of a post so search engines
+// can show the date, author, and image.
+func postJSONLD(post ambient.Post, meta socialMeta, author string, lang string) map[string]interface{} {
+	m := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         post.Title,
+		"description":      meta.Description,
+		"url":              meta.URL,
+		"mainEntityOfPage": meta.URL,
+		"datePublished":    post.Timestamp.Format(time.RFC3339),
+		"dateModified":     post.Updated.Format(time.RFC3339),
+		"inLanguage":       lang,
+	}
+
+	// Posts made before the updated date was tracked use the publish date.
+	if post.Updated.IsZero() {
+		m["dateModified"] = m["datePublished"]
+	}
+
+	if len(meta.Image) > 0 {
+		m["image"] = meta.Image
+	}
+
+	if len(author) > 0 {
+		m["author"] = map[string]interface{}{
+			"@type": "Person",
+			"name":  author,
+		}
+	}
+
+	if len(post.Tags) > 0 {
+		keywords := make([]string, 0, len(post.Tags))
+		for _, v := range post.Tags {
+			keywords = append(keywords, v.Name)
+		}
+		m["keywords"] = strings.Join(keywords, ", ")
+	}
+
+	return m
+}
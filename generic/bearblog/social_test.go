@@ -0,0 +1,68 @@
+package bearblog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestValidImageURL(t *testing.T) {
+	tests := map[string]bool{
+		"":                              true,
+		"/uploads/cover.png":            true,
+		"https://example.com/cover.png": true,
+		"http://example.com/cover.png":  true,
+		"//example.com/cover.png":       false,
+		"cover.png":                     false,
+		"javascript:alert(1)":           false,
+		"https:///cover.png":            false,
+	}
+
+	for in, expected := range tests {
+		if got := validImageURL(in); got != expected {
+			t.Errorf("%v: expected %v, got %v", in, expected, got)
+		}
+	}
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	if got := absoluteURL("https://example.com", "/uploads/a.png"); got != "https://example.com/uploads/a.png" {
+		t.Errorf("expected the site URL to be added, got %v", got)
+	}
+	if got := absoluteURL("https://example.com", "https://cdn.example.com/a.png"); got != "https://cdn.example.com/a.png" {
+		t.Errorf("expected the URL to not change, got %v", got)
+	}
+}
+
+func TestPostJSONLD(t *testing.T) {
+	published := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	post := ambient.Post{
+		Title:     "Hello",
+		Timestamp: published,
+		Tags:      ambient.TagList{{Name: "go"}, {Name: "web"}},
+	}
+	meta := socialMeta{Description: "Hi", URL: "https://example.com/hello"}
+
+	m := postJSONLD(post, meta, "Jo", "en")
+	if m["@type"] != "BlogPosting" || m["headline"] != "Hello" || m["inLanguage"] != "en" {
+		t.Errorf("unexpected JSON-LD: %v", m)
+	}
+	if m["dateModified"] != "2021-03-01T00:00:00Z" {
+		t.Errorf("expected the publish date when the post was never updated, got %v", m["dateModified"])
+	}
+	if m["keywords"] != "go, web" {
+		t.Errorf("expected keywords from tags, got %v", m["keywords"])
+	}
+	if _, ok := m["image"]; ok {
+		t.Error("expected no image")
+	}
+	if author, ok := m["author"].(map[string]interface{}); !ok || author["name"] != "Jo" {
+		t.Errorf("expected the author, got %v", m["author"])
+	}
+
+	m = postJSONLD(post, meta, "", "en")
+	if _, ok := m["author"]; ok {
+		t.Error("expected no author")
+	}
+}
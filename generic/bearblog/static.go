@@ -0,0 +1,536 @@
+package bearblog
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxStaticFiles limits how many files a static export can contain in case
+// links lead to an endless number of pages.
+const maxStaticFiles = 10000
+
+var (
+	// staticExcluded are the paths that are not part of the public site.
+	staticExcluded = []string{"/dashboard", "/login", "/api/", "/micropub"}
+
+	// staticLinkPattern matches root relative links in HTML attributes.
+	staticLinkPattern = regexp.MustCompile(`(\s(?:href|src|action)=")(/(?:[^/"][^"]*)?)(")`)
+
+	// staticCSSPattern matches root relative links in url() references of
+	// style sheets.
+	staticCSSPattern = regexp.MustCompile(`(url\(\s*['"]?)(/(?:[^/'"()\s][^'"()\s]*)?)(['"]?\s*\))`)
+
+	// staticSitemapPattern matches the sitemaps listed in robots.txt.
+	staticSitemapPattern = regexp.MustCompile(`(?im)^\s*sitemap:\s*(\S+)\s*$`)
+
+	// staticQueryUnsafe matches the characters of query keys and values that
+	// can't be used in folder names.
+	staticQueryUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// staticFetcher returns the status, content type, and body of a GET request
+// to the target, which is a path with an optional query string.
+type staticFetcher func(target string) (int, string, []byte, error)
+
+// staticWriter saves a file of the static export. The name uses forward
+// slashes.
+type staticWriter interface {
+	WriteFile(name string, b []byte) error
+}
+
+// staticDir writes the static export to a folder.
+type staticDir string
+
+// WriteFile writes a file to the folder and creates its parent folders.
+func (d staticDir) WriteFile(name string, b []byte) error {
+	if !validStaticName(name) {
+		return fmt.Errorf("static export file name is not allowed: %v", name)
+	}
+
+	fullPath := filepath.Join(string(d), filepath.FromSlash(name))
+	err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fullPath, b, 0644)
+}
+
+// staticZip writes the static export to a zip file.
+type staticZip struct {
+	zw  *zip.Writer
+	now time.Time
+}
+
+// WriteFile adds a file to the zip.
+func (z staticZip) WriteFile(name string, b []byte) error {
+	if !validStaticName(name) {
+		return fmt.Errorf("static export file name is not allowed: %v", name)
+	}
+
+	f, err := z.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: z.now,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	return err
+}
+
+// staticRecorder is the response of a request that is handled without a
+// network connection.
+type staticRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the response headers.
+func (rec *staticRecorder) Header() http.Header {
+	return rec.header
+}
+
+// Write adds to the body of the response.
+func (rec *staticRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+// WriteHeader sets the status of the response if it's not already set.
+func (rec *staticRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+// handlerFetcher returns a fetcher that sends requests straight to the
+// handler of the app.
+func handlerFetcher(h http.Handler) staticFetcher {
+	return func(target string) (int, string, []byte, error) {
+		r, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		r.RemoteAddr = "127.0.0.1:0"
+
+		rec := &staticRecorder{header: make(http.Header)}
+		h.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// Detect the content type like the server does when it's not set.
+		contentType := rec.header.Get("Content-Type")
+		if len(contentType) == 0 {
+			contentType = http.DetectContentType(rec.body.Bytes())
+		}
+
+		return rec.status, contentType, rec.body.Bytes(), nil
+	}
+}
+
+// httpFetcher returns a fetcher that requests pages from the site at the
+// base URL over HTTP. Redirects to other hosts are not followed.
+func httpFetcher(base string) staticFetcher {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			} else if req.URL.Host != via[0].URL.Host {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+	return func(target string) (int, string, []byte, error) {
+		resp, err := client.Get(base + target)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, "", nil, err
+		}
+
+		return resp.StatusCode, resp.Header.Get("Content-Type"), b, nil
+	}
+}
+
+// staticExport renders the pages of a site to files by following the links
+// on each page, starting from a list of paths.
+type staticExport struct {
+	fetch  staticFetcher
+	out    staticWriter
+	prefix string
+
+	queue  []*url.URL
+	queued map[string]bool
+}
+
+// newStaticExport returns a static export that removes the URL prefix from
+// the names of the files.
+func newStaticExport(fetch staticFetcher, out staticWriter, prefix string) *staticExport {
+	return &staticExport{
+		fetch:  fetch,
+		out:    out,
+		prefix: prefix,
+		queued: make(map[string]bool),
+	}
+}
+
+// Run saves the pages and the pages they link to. Pages that don't return a
+// 200 status are skipped. The number of files written is returned.
+func (e *staticExport) Run(paths []string) (int, error) {
+	for _, v := range paths {
+		u, err := url.Parse(v)
+		if err != nil {
+			return 0, err
+		}
+		e.add(u)
+	}
+
+	written := 0
+	for i := 0; i < len(e.queue); i++ {
+		if i >= maxStaticFiles {
+			return written, fmt.Errorf("static export has more than %v files", maxStaticFiles)
+		}
+
+		u := e.queue[i]
+		status, contentType, body, err := e.fetch(u.RequestURI())
+		if err != nil {
+			return written, err
+		} else if status != http.StatusOK {
+			continue
+		}
+
+		name := e.file(u)
+		if strings.HasPrefix(contentType, "text/html") {
+			body = e.rewrite(name, body)
+		} else if strings.HasPrefix(contentType, "text/css") {
+			body = e.rewriteCSS(name, body)
+		} else if name == "robots.txt" {
+			e.addSitemaps(body)
+		}
+
+		err = e.out.WriteFile(name, body)
+		if err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	if written == 0 {
+		return 0, errors.New("static export didn't find any pages")
+	}
+
+	return written, nil
+}
+
+// add queues a link if it's part of the public site and hasn't been queued.
+// Returns false if the link is not part of the export.
+func (e *staticExport) add(u *url.URL) bool {
+	p := strings.TrimPrefix(u.Path, e.prefix)
+	for _, v := range staticExcluded {
+		if strings.HasPrefix(p, v) {
+			return false
+		}
+	}
+
+	// Search results and previews can't be exported.
+	q := u.Query()
+	if q.Has("q") || q.Has("preview") {
+		return false
+	}
+
+	name := e.file(u)
+	if !e.queued[name] {
+		e.queued[name] = true
+		e.queue = append(e.queue, u)
+	}
+
+	return true
+}
+
+// file returns the name of the file a link is saved to.
+func (e *staticExport) file(u *url.URL) string {
+	return staticFile(strings.TrimPrefix(u.Path, e.prefix), u.Query())
+}
+
+// rewrite queues the links in a page and changes them to relative links
+// between the files of the export.
+func (e *staticExport) rewrite(name string, body []byte) []byte {
+	return staticLinkPattern.ReplaceAllFunc(body, func(m []byte) []byte {
+		parts := staticLinkPattern.FindSubmatch(m)
+		link, ok := e.link(name, html.UnescapeString(string(parts[2])))
+		if !ok {
+			return m
+		}
+
+		return []byte(string(parts[1]) + html.EscapeString(link) + string(parts[3]))
+	})
+}
+
+// rewriteCSS queues the files in the url() references of a style sheet, like
+// fonts and images, and changes them to relative links.
+func (e *staticExport) rewriteCSS(name string, body []byte) []byte {
+	return staticCSSPattern.ReplaceAllFunc(body, func(m []byte) []byte {
+		parts := staticCSSPattern.FindSubmatch(m)
+		link, ok := e.link(name, string(parts[2]))
+		if !ok {
+			return m
+		}
+
+		return []byte(string(parts[1]) + link + string(parts[3]))
+	})
+}
+
+// link queues a root relative link and returns the relative link to it from
+// the file. Returns false if the link is not part of the export.
+func (e *staticExport) link(name string, s string) (string, bool) {
+	u, err := url.Parse(s)
+	if err != nil || !e.add(u) {
+		return "", false
+	}
+
+	target := e.file(u)
+	link := staticLink(name, target)
+	// Keep the query on files like scripts so they are still cache busted.
+	if !isStaticPage(target) && len(u.RawQuery) > 0 {
+		link += "?" + u.RawQuery
+	}
+	if len(u.Fragment) > 0 {
+		link += "#" + u.Fragment
+	}
+
+	return link, true
+}
+
+// addSitemaps queues the sitemaps listed in robots.txt. The file isn't
+// changed since sitemaps must be listed with the full URL.
+func (e *staticExport) addSitemaps(body []byte) {
+	for _, m := range staticSitemapPattern.FindAllSubmatch(body, -1) {
+		u, err := url.Parse(string(m[1]))
+		if err != nil || len(u.Path) == 0 {
+			continue
+		}
+
+		e.add(&url.URL{Path: u.Path, RawQuery: u.RawQuery})
+	}
+}
+
+// staticFile returns the name of the file a path is saved to. Pages are saved
+// as index.html in a folder named after the path and query string so they
+// can be linked to without the file name. Other files keep their path.
+func staticFile(p string, q url.Values) string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if len(path.Ext(p)) > 0 {
+		return p
+	}
+
+	parts := make([]string, 0)
+	if len(p) > 0 {
+		parts = append(parts, p)
+	}
+
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := staticQueryUnsafe.ReplaceAllString(k, "_")
+		for _, v := range q[k] {
+			// The dash between the key and value means a part can never be
+			// a dot segment.
+			parts = append(parts, key+"-"+staticQueryUnsafe.ReplaceAllString(v, "_"))
+		}
+	}
+
+	return strings.Join(append(parts, "index.html"), "/")
+}
+
+// validStaticName returns true if the file name stays inside the export. It
+// must be a clean relative path without dot segments.
+func validStaticName(name string) bool {
+	if len(name) == 0 || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return false
+	} else if path.Clean(name) != name {
+		return false
+	}
+
+	for _, v := range strings.Split(name, "/") {
+		if v == ".." || v == "." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isStaticPage returns true if the file is a page saved as index.html.
+func isStaticPage(name string) bool {
+	return name == "index.html" || strings.HasSuffix(name, "/index.html")
+}
+
+// staticLink returns the relative link from one file of the export to
+// another. Links to pages point to their folder.
+func staticLink(from string, to string) string {
+	target := to
+	if isStaticPage(to) {
+		target = path.Dir(to)
+	}
+
+	fromParts := strings.Split(path.Dir(from), "/")
+	if fromParts[0] == "." {
+		fromParts = nil
+	}
+	toParts := strings.Split(target, "/")
+	if toParts[0] == "." {
+		toParts = nil
+	}
+
+	// Remove the folders both files are in.
+	i := 0
+	for i < len(fromParts) && i < len(toParts)-1 && fromParts[i] == toParts[i] {
+		i++
+	}
+	if isStaticPage(to) {
+		for i < len(fromParts) && i < len(toParts) && fromParts[i] == toParts[i] {
+			i++
+		}
+	}
+
+	parts := make([]string, 0)
+	for range fromParts[i:] {
+		parts = append(parts, "..")
+	}
+	for _, v := range toParts[i:] {
+		parts = append(parts, url.PathEscape(v))
+	}
+
+	link := strings.Join(parts, "/")
+	if isStaticPage(to) {
+		if len(link) == 0 {
+			return "./"
+		}
+		return link + "/"
+	}
+
+	return link
+}
+
+// SetExportHandler sets the handler of the app so static exports can render
+// pages without a network connection. The dashboard requests pages from the
+// running site if it's not set.
+func (p *Plugin) SetExportHandler(h http.Handler) {
+	p.exportHandler = h
+}
+
+// ExportDir renders every public page of the site to files in the folder so
+// it can be served by any static file server. SetExportHandler must be called
+// first.
+func (p *Plugin) ExportDir(dir string) (int, error) {
+	if p.exportHandler == nil {
+		return 0, errors.New("bearblog: export handler is not set")
+	}
+
+	return p.exportStaticSite(handlerFetcher(p.exportHandler), staticDir(dir))
+}
+
+// ExportZip renders every public page of the site to a zip file.
+// SetExportHandler must be called first.
+func (p *Plugin) ExportZip(w io.Writer) (int, error) {
+	if p.exportHandler == nil {
+		return 0, errors.New("bearblog: export handler is not set")
+	}
+
+	zw := zip.NewWriter(w)
+	n, err := p.exportStaticSite(handlerFetcher(p.exportHandler), staticZip{zw: zw, now: time.Now()})
+	if err != nil {
+		return n, err
+	}
+
+	return n, zw.Close()
+}
+
+// exportStaticSite renders the site with the fetcher and saves the files.
+func (p *Plugin) exportStaticSite(fetch staticFetcher, out staticWriter) (int, error) {
+	if p.Site == nil {
+		return 0, errors.New("bearblog: plugin is not enabled")
+	}
+
+	paths, err := p.staticPaths()
+	if err != nil {
+		return 0, err
+	}
+
+	return newStaticExport(fetch, out, p.Path("")).Run(paths)
+}
+
+// staticPaths returns the pages a static export starts from. Other pages are
+// found by following links, like the feeds and sitemap linked in the head of
+// each page. Only robots.txt is added since it's always at the same path.
+// Unlisted posts are included because readers can have their URL.
+func (p *Plugin) staticPaths() ([]string, error) {
+	paths := []string{"/", "/blog", "/robots.txt"}
+
+	postsAndPages, err := p.postsAndPages(true)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := p.postAccesses()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range postsAndPages {
+		if !scheduled(v.Post) && !accesses[v.ID].protected() {
+			paths = append(paths, "/"+v.URL)
+		}
+	}
+
+	posts, err := p.livePostsAndPages()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range liveTags(posts) {
+		paths = append(paths, tagURL(v.Name))
+	}
+
+	groups, err := p.seriesGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range groups {
+		paths = append(paths, seriesURL(v.slug))
+	}
+
+	for i := range paths {
+		paths[i] = p.Path(paths[i])
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,193 @@
+package bearblog
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestStaticFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		query    url.Values
+		expected string
+	}{
+		{"/", nil, "index.html"},
+		{"/blog", nil, "blog/index.html"},
+		{"/blog/tag/go", nil, "blog/tag/go/index.html"},
+		{"/blog", url.Values{"page": {"2"}, "lang": {"de"}}, "blog/lang-de/page-2/index.html"},
+		{"/rss.xml", nil, "rss.xml"},
+		{"/plugins/bearcss/css/bear.css", url.Values{"v": {"1.0.0"}}, "plugins/bearcss/css/bear.css"},
+		{"/blog", url.Values{"../../../tmp/pwn": {"1"}}, "blog/.._.._.._tmp_pwn-1/index.html"},
+		{"/blog", url.Values{"tag": {"../../x y"}}, "blog/tag-.._.._x_y/index.html"},
+		{"/blog/../../../etc", nil, "etc/index.html"},
+	}
+
+	for _, tt := range tests {
+		got := staticFile(tt.path, tt.query)
+		if got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.path, tt.expected, got)
+		} else if !validStaticName(got) {
+			t.Errorf("%v: expected a valid name, got %v", tt.path, got)
+		}
+	}
+}
+
+func TestValidStaticName(t *testing.T) {
+	tests := map[string]bool{
+		"index.html":             true,
+		"blog/tag-go/index.html": true,
+		"blog/..-1/index.html":   true,
+		"":                       false,
+		"/etc/passwd":            false,
+		"../index.html":          false,
+		"blog/../../index.html":  false,
+		"blog/./index.html":      false,
+		"blog//index.html":       false,
+		"blog\\..\\index.html":   false,
+	}
+
+	for in, expected := range tests {
+		if got := validStaticName(in); got != expected {
+			t.Errorf("%v: expected %v, got %v", in, expected, got)
+		}
+	}
+}
+
+func TestStaticLink(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected string
+	}{
+		{"index.html", "index.html", "./"},
+		{"index.html", "blog/index.html", "blog/"},
+		{"blog/index.html", "index.html", "../"},
+		{"blog/index.html", "blog/index.html", "./"},
+		{"blog/index.html", "blog/tag/go/index.html", "tag/go/"},
+		{"blog/tag/go/index.html", "blog/tag/web/index.html", "../web/"},
+		{"hello/index.html", "plugins/bearcss/css/bear.css", "../plugins/bearcss/css/bear.css"},
+		{"index.html", "rss.xml", "rss.xml"},
+		{"blog/tag/go/index.html", "blog/tag/c sharp/index.html", "../c%20sharp/"},
+	}
+
+	for _, tt := range tests {
+		if got := staticLink(tt.from, tt.to); got != tt.expected {
+			t.Errorf("%v to %v: expected %v, got %v", tt.from, tt.to, tt.expected, got)
+		}
+	}
+}
+
+func TestStaticExport(t *testing.T) {
+	pages := map[string]string{
+		"/pre/":                    `<a href="/pre/hello">Hello</a> <a href="/pre/dashboard">Dashboard</a>`,
+		"/pre/hello":               `<link href="/pre/a.css?v=1"><a href="/pre/blog?page=2&amp;lang=de#top">Next</a> <a href="https://example.com/">Out</a>`,
+		"/pre/a.css?v=1":           `body {}`,
+		"/pre/blog?lang=de&page=2": `<a href="/pre/blog?q=go">Search</a> <a href="/pre/missing">Missing</a>`,
+	}
+	fetch := func(target string) (int, string, []byte, error) {
+		// Query values are sorted so the keys match.
+		u, _ := url.Parse(target)
+		if len(u.RawQuery) > 0 {
+			target = u.Path + "?" + u.Query().Encode()
+		}
+
+		body, ok := pages[target]
+		if !ok {
+			return http.StatusNotFound, "text/plain", nil, nil
+		}
+
+		contentType := "text/html; charset=utf-8"
+		if strings.HasSuffix(u.Path, ".css") {
+			contentType = "text/css"
+		}
+
+		return http.StatusOK, contentType, []byte(body), nil
+	}
+
+	files := make(staticFiles)
+	n, err := newStaticExport(fetch, files, "/pre").Run([]string{"/pre/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || len(files) != 4 {
+		t.Fatalf("expected 4 files, got %v: %v", n, files)
+	}
+
+	expected := map[string]string{
+		"index.html":                     `<a href="hello/">Hello</a> <a href="/pre/dashboard">Dashboard</a>`,
+		"hello/index.html":               `<link href="../a.css?v=1"><a href="../blog/lang-de/page-2/#top">Next</a> <a href="https://example.com/">Out</a>`,
+		"a.css":                          `body {}`,
+		"blog/lang-de/page-2/index.html": `<a href="/pre/blog?q=go">Search</a> <a href="../../../missing/">Missing</a>`,
+	}
+	for name, body := range expected {
+		if got := files[name]; got != body {
+			t.Errorf("%v: expected %v, got %v", name, body, got)
+		}
+	}
+}
+
+func TestStaticExportDiscovery(t *testing.T) {
+	pages := map[string]string{
+		"/":                  `<link rel="alternate" href="/atom.xml"><link rel="sitemap" href="/sitemap.xml"><link href="/css/a.css">`,
+		"/css/a.css":         `body { background: url("/img/bg.png"); } @font-face { src: url(/fonts/f.woff2?v=2); } a { background: url(//cdn.example.com/x.png); }`,
+		"/img/bg.png":        "png",
+		"/fonts/f.woff2?v=2": "font",
+		"/atom.xml":          "<feed></feed>",
+		"/sitemap.xml":       "<urlset></urlset>",
+		"/robots.txt":        "User-agent: *\nAllow: /\nSitemap: https://example.com/news-sitemap.xml\n",
+		"/news-sitemap.xml":  "<urlset></urlset>",
+	}
+	contentTypes := map[string]string{
+		".css":   "text/css; charset=utf-8",
+		".txt":   "text/plain; charset=utf-8",
+		".xml":   "application/xml",
+		".png":   "image/png",
+		".woff2": "font/woff2",
+	}
+	fetch := func(target string) (int, string, []byte, error) {
+		u, _ := url.Parse(target)
+		body, ok := pages[target]
+		if !ok {
+			return http.StatusNotFound, "text/plain", nil, nil
+		}
+
+		contentType, ok := contentTypes[path.Ext(u.Path)]
+		if !ok {
+			contentType = "text/html; charset=utf-8"
+		}
+
+		return http.StatusOK, contentType, []byte(body), nil
+	}
+
+	files := make(staticFiles)
+	_, err := newStaticExport(fetch, files, "").Run([]string{"/", "/robots.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"atom.xml", "sitemap.xml", "news-sitemap.xml", "img/bg.png", "fonts/f.woff2"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected %v to be found, got %v", name, files)
+		}
+	}
+
+	expected := `body { background: url("../img/bg.png"); } @font-face { src: url(../fonts/f.woff2?v=2); } a { background: url(//cdn.example.com/x.png); }`
+	if got := files["css/a.css"]; got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if got := files["robots.txt"]; got != pages["/robots.txt"] {
+		t.Errorf("expected robots.txt to not change, got %v", got)
+	}
+}
+
+// staticFiles saves the files of a static export in memory.
+type staticFiles map[string]string
+
+// WriteFile saves the file.
+func (f staticFiles) WriteFile(name string, b []byte) error {
+	f[name] = string(b)
+	return nil
+}
@@ -0,0 +1,132 @@
+package bearblog
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Data that doesn't fit on an ambient.Post is stored as JSON in plugin
+// settings. The settings are not returned by Settings() so they don't show on
+// the plugin settings page. All of the data is saved in the dataAll setting
+// and the other names are the keys in it.
+const (
+	dataAll       = "data"
+	dataRevisions = "data.revisions"
+	dataUploads   = "data.uploads"
+	dataSlugs     = "data.slugs"
+	dataUsers     = "data.users"
+	dataAuthors   = "data.authors"
+	dataDrafts    = "data.drafts"
+	dataAudit     = "data.audit"
+	dataSecret    = "data.secret"
+	dataComments  = "data.comments"
+	dataClosed    = "data.commentsclosed"
+	dataSeries    = "data.series"
+	dataTOC       = "data.toc"
+	dataTrash     = "data.trash"
+	dataAccess    = "data.access"
+	dataLanguages = "data.languages"
+	dataSocial    = "data.social"
+	dataMenu      = "data.menu"
+)
+
+// dataCache has the data from plugin settings. Changes are kept in memory
+// until flushData saves them so several changes only save the storage once.
+type dataCache struct {
+	mu sync.Mutex
+	// values is nil until the data is loaded.
+	values map[string]json.RawMessage
+	dirty  bool
+}
+
+// newDataCache returns a data cache that loads the data when it's first used.
+func newDataCache() *dataCache {
+	return &dataCache{}
+}
+
+// loadData reads the JSON saved with saveData into v. If the data has not
+// been saved yet, v is left unchanged.
+func (p *Plugin) loadData(name string, v interface{}) error {
+	c := p.cache
+	c.mu.Lock()
+	b, err := p.cachedData(name)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(b) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// saveData sets the data to v as JSON. The storage is saved by flushData.
+func (p *Plugin) saveData(name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c := p.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = p.cachedData(name)
+	if err != nil {
+		return err
+	}
+
+	c.values[name] = b
+	c.dirty = true
+
+	return nil
+}
+
+// flushData saves the data changed since the last flush to storage.
+func (p *Plugin) flushData() error {
+	c := p.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(c.values)
+	if err != nil {
+		return err
+	}
+
+	err = p.Site.SetPluginSetting(dataAll, string(b))
+	if err != nil {
+		return err
+	}
+	c.dirty = false
+
+	return nil
+}
+
+// cachedData returns the JSON of the data. The data is loaded from storage
+// the first time. The cache lock must be held.
+func (p *Plugin) cachedData(name string) (json.RawMessage, error) {
+	c := p.cache
+	if c.values == nil {
+		s, err := p.Site.PluginSettingString(dataAll)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]json.RawMessage)
+		if len(s) > 0 {
+			err = json.Unmarshal([]byte(s), &values)
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.values = values
+	}
+
+	return c.values[name], nil
+}
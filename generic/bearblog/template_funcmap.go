@@ -27,13 +27,27 @@ func (p *Plugin) FuncMap() func(r *http.Request) template.FuncMap {
 			}
 			return tt.Format("02 Jan, 2006")
 		}
+		fm["bearblog_StampDateTime"] = func(t string) string {
+			tt, err := time.Parse(time.RFC3339, t)
+			if err != nil {
+				return t
+			}
+			return tt.Format("2006-01-02 15:04")
+		}
 		fm["bearblog_PublishedPages"] = func() []ambient.Post {
-			arr, err := p.Site.PublishedPages()
+			arr, err := p.livePages()
 			if err != nil {
 				p.Log.Warn("bearblog: error getting published pages: %v", err.Error())
 			}
 			return arr
 		}
+		fm["bearblog_Menu"] = func() []navLink {
+			arr, err := p.navLinks()
+			if err != nil {
+				p.Log.Warn("bearblog: error getting menu: %v", err.Error())
+			}
+			return arr
+		}
 		fm["bearblog_SiteSubtitle"] = func() string {
 			subtitle, err := p.Site.PluginSettingString(Subtitle)
 			if err != nil {
@@ -62,11 +76,28 @@ func (p *Plugin) FuncMap() func(r *http.Request) template.FuncMap {
 			return path.Join(siteURL, r.URL.Path)
 		}
 		fm["bearblog_MFAEnabled"] = func() bool {
-			mfakey, err := p.Site.PluginSettingString(MFAKey)
+			enabled, err := p.mfaEnabled()
+			if err != nil {
+				p.Log.Warn("bearblog: error getting MFA keys: %v", err.Error())
+			}
+			return enabled
+		}
+		fm["bearblog_Admin"] = func() bool {
+			cu, err := p.currentUser(r)
+			return err == nil && cu.Role == roleAdmin
+		}
+		fm["bearblog_RelatedPosts"] = func(ID string) []ambient.PostWithID {
+			arr, err := p.relatedPosts(ID)
 			if err != nil {
-				p.Log.Warn("bearblog: error getting MFA key: %v", err.Error())
+				p.Log.Warn("bearblog: error getting related posts: %v", err.Error())
 			}
-			return len(mfakey) > 0
+			return arr
+		}
+		fm["bearblog_WordCount"] = func(content string) int {
+			return wordCount(content)
+		}
+		fm["bearblog_ReadingTime"] = func(content string) int {
+			return readingTime(wordCount(content))
 		}
 
 		return fm
@@ -0,0 +1,269 @@
+package bearblog
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+// defaultTrashRetention is the number of days deleted posts are kept when the
+// retention setting is missing or invalid.
+const defaultTrashRetention = 30
+
+// trashedPost is a deleted post that can still be restored.
+type trashedPost struct {
+	Post      ambient.Post `json:"post"`
+	Deleted   time.Time    `json:"deleted"`
+	DeletedBy string       `json:"deletedby"`
+}
+
+// trashRetention returns the number of days to keep deleted posts. Zero means
+// they are kept until they are purged.
+func (p *Plugin) trashRetention() int {
+	s, err := p.Site.PluginSettingString(TrashRetention)
+	if err != nil {
+		p.Log.Warn("bearblog: error getting trash retention: %v", err.Error())
+		return defaultTrashRetention
+	}
+
+	days, err := strconv.Atoi(s)
+	if err != nil || days < 0 {
+		return defaultTrashRetention
+	}
+
+	return days
+}
+
+// trashedPosts returns the deleted posts mapped to the post ID.
+func (p *Plugin) trashedPosts() (map[string]trashedPost, error) {
+	all := make(map[string]trashedPost)
+	err := p.loadData(dataTrash, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// trashPost moves a post to the trash. The data stored about the post, like
+// revisions and comments, is kept so the post can be restored as it was.
+func (p *Plugin) trashPost(ID string, username string) error {
+	post, err := p.postByID(ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.setTrashedPost(ID, &trashedPost{
+		Post:      post,
+		Deleted:   time.Now(),
+		DeletedBy: username,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.deletePost(ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.deleteDraft(ID)
+	if err != nil {
+		return err
+	}
+
+	// Deleting is a good time to remove the posts that expired since GET
+	// requests don't change anything.
+	return p.purgeExpiredTrash()
+}
+
+// restorePost moves a post out of the trash. If another post has taken the
+// slug since it was deleted, a new slug is generated from the title. The
+// returned bool is false if the post is not in the trash.
+func (p *Plugin) restorePost(ID string) (bool, error) {
+	all, err := p.trashedPosts()
+	if err != nil {
+		return false, err
+	}
+
+	tp, ok := all[ID]
+	if !ok {
+		return false, nil
+	}
+
+	post := tp.Post
+	taken, err := p.slugTaken(ID, post.URL)
+	if err != nil {
+		return false, err
+	} else if taken {
+		post.URL, _, err = p.checkSlug(ID, "", post.Title)
+		if err != nil {
+			return false, err
+		}
+
+		err = p.recordSlugChange(ID, "", post.URL)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = p.savePost(ID, post)
+	if err != nil {
+		return false, err
+	}
+
+	return true, p.setTrashedPost(ID, nil)
+}
+
+// purgePost permanently removes a post from the trash along with all of the
+// data stored about it. Posts that are not in the trash are left alone so the
+// data of live posts is never removed. The returned bool is false if the post
+// is not in the trash.
+func (p *Plugin) purgePost(ID string) (bool, error) {
+	all, err := p.trashedPosts()
+	if err != nil {
+		return false, err
+	} else if _, ok := all[ID]; !ok {
+		return false, nil
+	}
+
+	return true, p.purgeData(ID)
+}
+
+// purgeData removes the post from the trash and all of the data stored about
+// it.
+func (p *Plugin) purgeData(ID string) error {
+	err := p.setTrashedPost(ID, nil)
+	if err != nil {
+		return err
+	}
+
+	err = p.deleteRevisions(ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.deleteSlugs(ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.setPostAuthor(ID, "")
+	if err != nil {
+		return err
+	}
+
+	err = p.deleteDraft(ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.deleteComments(ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.setPostSeries(ID, "", 0)
+	if err != nil {
+		return err
+	}
+
+	err = p.setPostTOC(ID, false)
+	if err != nil {
+		return err
+	}
+
+	err = p.setPostAccess(ID, postAccess{})
+	if err != nil {
+		return err
+	}
+
+	err = p.setPostLanguage(ID, postLanguage{})
+	if err != nil {
+		return err
+	}
+
+	return p.setPostSocial(ID, postSocial{})
+}
+
+// purgeExpiredTrash permanently removes the posts that have been in the trash
+// longer than the retention period.
+func (p *Plugin) purgeExpiredTrash() error {
+	all, err := p.trashedPosts()
+	if err != nil {
+		return err
+	}
+
+	for _, ID := range expiredTrash(all, p.trashRetention(), time.Now()) {
+		err = p.purgeData(ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setTrashedPost adds a post to the trash or removes it if tp is nil.
+func (p *Plugin) setTrashedPost(ID string, tp *trashedPost) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]trashedPost)
+	err := p.loadData(dataTrash, &all)
+	if err != nil {
+		return err
+	}
+
+	if tp == nil {
+		if _, ok := all[ID]; !ok {
+			return nil
+		}
+		delete(all, ID)
+	} else {
+		all[ID] = *tp
+	}
+
+	return p.saveData(dataTrash, all)
+}
+
+// inTrash returns true if all of the IDs are posts in the trash.
+func inTrash(all map[string]trashedPost, IDs []string) bool {
+	for _, ID := range IDs {
+		if _, ok := all[ID]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// trashExpires returns when a post deleted at the time will be purged or a
+// zero time if deleted posts are kept until they are purged.
+func trashExpires(deleted time.Time, retention int) time.Time {
+	if retention == 0 {
+		return time.Time{}
+	}
+
+	return deleted.AddDate(0, 0, retention)
+}
+
+// expiredTrash returns the IDs of the deleted posts that are past the
+// retention period, oldest first.
+func expiredTrash(all map[string]trashedPost, retention int, now time.Time) []string {
+	arr := make([]string, 0)
+	for ID, v := range all {
+		expires := trashExpires(v.Deleted, retention)
+		if !expires.IsZero() && !now.Before(expires) {
+			arr = append(arr, ID)
+		}
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		return all[arr[i]].Deleted.Before(all[arr[j]].Deleted)
+	})
+
+	return arr
+}
@@ -0,0 +1,52 @@
+package bearblog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiredTrash(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	all := map[string]trashedPost{
+		"new":    {Deleted: now.AddDate(0, 0, -1)},
+		"old":    {Deleted: now.AddDate(0, 0, -30)},
+		"oldest": {Deleted: now.AddDate(0, 0, -90)},
+	}
+
+	got := expiredTrash(all, 30, now)
+	if len(got) != 2 || got[0] != "oldest" || got[1] != "old" {
+		t.Errorf("expected oldest and old, got %v", got)
+	}
+
+	if got := expiredTrash(all, 0, now); len(got) != 0 {
+		t.Errorf("expected nothing to expire with no retention, got %v", got)
+	}
+}
+
+func TestTrashExpires(t *testing.T) {
+	deleted := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	if got := trashExpires(deleted, 7); !got.Equal(deleted.AddDate(0, 0, 7)) {
+		t.Errorf("expected a week later, got %v", got)
+	}
+	if got := trashExpires(deleted, 0); !got.IsZero() {
+		t.Errorf("expected zero time, got %v", got)
+	}
+}
+
+func TestInTrash(t *testing.T) {
+	all := map[string]trashedPost{
+		"deleted": {},
+		"other":   {},
+	}
+
+	if !inTrash(all, []string{"deleted", "other"}) {
+		t.Error("expected posts in the trash to be found")
+	}
+	if inTrash(all, []string{"deleted", "live"}) {
+		t.Error("expected a live post to not be in the trash")
+	}
+	if !inTrash(all, nil) {
+		t.Error("expected no posts to be in the trash")
+	}
+}
@@ -0,0 +1,237 @@
+package bearblog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/plugin/pkg/photo"
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// defaultUploadFolder is where uploads are stored on the local disk when
+	// a different store is not set.
+	defaultUploadFolder = "storage/uploads"
+	// maxUploadSize is the largest image that can be uploaded.
+	maxUploadSize = 10 << 20
+	// maxUploadPixels prevents decoding images that would use too much
+	// memory.
+	maxUploadPixels = 50000000
+)
+
+var (
+	// uploadWidths are the widths of the resized variants created for images
+	// that are wider.
+	uploadWidths = []int{640, 1280}
+
+	// uploadTypes are the allowed content types and their file extensions.
+	uploadTypes = map[string]string{
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+		"image/jpeg": ".jpg",
+	}
+
+	errUnsupportedImage = errors.New("only PNG, GIF, and JPEG images are supported")
+	errImageTooLarge    = errors.New("image dimensions are too large")
+)
+
+// upload is an image uploaded from the dashboard.
+type upload struct {
+	Name     string          `json:"name"`
+	Filename string          `json:"filename"`
+	Width    int             `json:"width"`
+	Height   int             `json:"height"`
+	Variants []uploadVariant `json:"variants"`
+	Uploaded time.Time       `json:"uploaded"`
+	Username string          `json:"username"`
+}
+
+// uploadVariant is a resized copy of an uploaded image.
+type uploadVariant struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// uploads returns the uploaded images from oldest to newest.
+func (p *Plugin) uploads() ([]upload, error) {
+	arr := make([]upload, 0)
+	err := p.loadData(dataUploads, &arr)
+	if err != nil {
+		return nil, err
+	}
+
+	return arr, nil
+}
+
+// addUpload adds an uploaded image to the list of uploads.
+func (p *Plugin) addUpload(u upload) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	arr := make([]upload, 0)
+	err := p.loadData(dataUploads, &arr)
+	if err != nil {
+		return err
+	}
+
+	arr = append(arr, u)
+
+	return p.saveData(dataUploads, arr)
+}
+
+// uploadURL returns the public URL of an uploaded file.
+func (p *Plugin) uploadURL(name string) string {
+	return p.Path("/uploads/" + name)
+}
+
+// uploadMarkdown returns the Markdown to show an uploaded image in a post. If
+// the image was resized, the largest variant is shown with a link to the
+// original.
+func (p *Plugin) uploadMarkdown(u upload) string {
+	alt := strings.TrimSuffix(u.Filename, path.Ext(u.Filename))
+	alt = strings.NewReplacer("[", "", "]", "").Replace(alt)
+
+	if len(u.Variants) == 0 {
+		return fmt.Sprintf("![%v](%v)", alt, p.uploadURL(u.Name))
+	}
+
+	largest := u.Variants[len(u.Variants)-1]
+	return fmt.Sprintf("[![%v](%v)](%v)", alt, p.uploadURL(largest.Name), p.uploadURL(u.Name))
+}
+
+// processImage validates an uploaded image, fixes the orientation of photos,
+// and creates resized variants. The files to store are returned by name and
+// each name starts with the ID.
+func processImage(file multipart.File, ID string) (upload, map[string][]byte, error) {
+	var u upload
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return u, nil, err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return u, nil, err
+	}
+
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := uploadTypes[contentType]
+	if !ok {
+		return u, nil, errUnsupportedImage
+	}
+
+	config, err := photo.ImageDimensions(file)
+	if err != nil {
+		return u, nil, err
+	} else if config.Width*config.Height > maxUploadPixels {
+		return u, nil, errImageTooLarge
+	}
+
+	u.Name = ID + ext
+	u.Width = config.Width
+	u.Height = config.Height
+
+	var img image.Image
+	var original []byte
+	var format imaging.Format
+	switch contentType {
+	case "image/jpeg":
+		img, original, err = decodeJPEG(file)
+		format = imaging.JPEG
+	case "image/png":
+		original, err = ioutil.ReadAll(file)
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err == nil {
+			img, err = photo.PNGToImage(file)
+		}
+		format = imaging.PNG
+	default:
+		// GIFs are kept as-is without variants so animations still work.
+		original, err = ioutil.ReadAll(file)
+	}
+	if err != nil {
+		return u, nil, err
+	}
+
+	files := map[string][]byte{
+		u.Name: original,
+	}
+
+	if img == nil {
+		return u, files, nil
+	}
+
+	// The orientation may have changed so use the decoded size.
+	u.Width = img.Bounds().Dx()
+	u.Height = img.Bounds().Dy()
+
+	u.Variants = make([]uploadVariant, 0)
+	for _, width := range uploadWidths {
+		if width >= u.Width {
+			continue
+		}
+
+		resized := imaging.Resize(img, width, 0, imaging.Lanczos)
+		buf := new(bytes.Buffer)
+		err = imaging.Encode(buf, resized, format, imaging.JPEGQuality(photo.JPGQuality))
+		if err != nil {
+			return u, nil, err
+		}
+
+		v := uploadVariant{
+			Name:   fmt.Sprintf("%v-%v%v", ID, width, ext),
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+		}
+		files[v.Name] = buf.Bytes()
+		u.Variants = append(u.Variants, v)
+	}
+
+	return u, files, nil
+}
+
+// decodeJPEG returns a JPEG with the orientation from the camera applied and
+// the bytes of the corrected file.
+func decodeJPEG(file multipart.File) (image.Image, []byte, error) {
+	tmp, err := ioutil.TempFile("", "bearblog-*.jpg")
+	if err != nil {
+		return nil, nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	err = photo.JPGToFile(file, tmp.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An error is returned when the photo doesn't have an orientation which
+	// is normal for images that didn't come from a camera.
+	photo.FixRotation(tmp.Name())
+
+	b, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return img, b, nil
+}
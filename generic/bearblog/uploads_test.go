@@ -0,0 +1,64 @@
+package bearblog
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+// testFile is an in-memory multipart.File.
+type testFile struct {
+	*bytes.Reader
+}
+
+func (testFile) Close() error { return nil }
+
+func TestProcessImagePNG(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 2000, 1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, files, err := processImage(testFile{bytes.NewReader(buf.Bytes())}, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Name != "abc.png" || u.Width != 2000 || u.Height != 1000 {
+		t.Errorf("unexpected upload: %+v", u)
+	}
+	if len(u.Variants) != 2 || u.Variants[0].Name != "abc-640.png" || u.Variants[0].Height != 320 {
+		t.Errorf("unexpected variants: %+v", u.Variants)
+	}
+	if len(files) != 3 || !bytes.Equal(files["abc.png"], buf.Bytes()) {
+		t.Errorf("expected original and 2 variants, got %v files", len(files))
+	}
+}
+
+func TestProcessImageGIF(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := gif.Encode(buf, image.NewPaletted(image.Rect(0, 0, 1500, 10), color.Palette{color.Black, color.White}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, files, err := processImage(testFile{bytes.NewReader(buf.Bytes())}, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Name != "abc.gif" || len(u.Variants) != 0 || len(files) != 1 {
+		t.Errorf("expected GIF without variants, got %+v", u)
+	}
+}
+
+func TestProcessImageUnsupported(t *testing.T) {
+	_, _, err := processImage(testFile{bytes.NewReader([]byte("<svg></svg>"))}, "abc")
+	if err != errUnsupportedImage {
+		t.Errorf("expected unsupported image error, got %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package bearblog
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ambientkit/plugin/pkg/passhash"
+	"github.com/ambientkit/plugin/pkg/totp"
+)
+
+// Roles that can be given to users.
+const (
+	// roleAdmin can do everything including managing users and the site.
+	roleAdmin = "admin"
+	// roleEditor can create and edit all posts.
+	roleEditor = "editor"
+	// roleAuthor can only create posts and edit their own posts.
+	roleAuthor = "author"
+)
+
+// roles is the list of roles in the order they are shown.
+var roles = []string{roleAdmin, roleEditor, roleAuthor}
+
+// usernamePattern is the allowed format of usernames.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{1,31}$`)
+
+var (
+	errLoginFailed = errors.New("username or password is not correct")
+	errMFAFailed   = errors.New("MFA token is not correct")
+	errUnknownUser = errors.New("user does not exist")
+)
+
+// unknownUserHash is a password hash with the default cost that is checked
+// when the username doesn't exist so the response takes as long as it does
+// for a wrong password. Otherwise the time would show which users exist.
+const unknownUserHash = "$2a$10$akTgTOPk6CxOFtwJAvGqduQE/sEYqmG9q.59Z86sbzW0LEcMMd.gO"
+
+// sessionLoginTime is the session key for the time the user logged in. It's
+// used to end sessions from before a user with the same username was created.
+const sessionLoginTime = "bearblog_login_time"
+
+// user is an account that can login to the dashboard. The site owner from the
+// plugin settings is not stored as a user.
+type user struct {
+	Username     string    `json:"username"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password"`
+	Role         string    `json:"role"`
+	MFAKey       string    `json:"mfakey"`
+	Created      time.Time `json:"created"`
+}
+
+// currentUser is the authenticated user and their role.
+type currentUser struct {
+	Username string
+	Role     string
+}
+
+// users returns the stored users sorted by username.
+func (p *Plugin) users() ([]user, error) {
+	all := make(map[string]user)
+	err := p.loadData(dataUsers, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make([]user, 0, len(all))
+	for _, v := range all {
+		arr = append(arr, v)
+	}
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Username < arr[j].Username
+	})
+
+	return arr, nil
+}
+
+// user returns a stored user.
+func (p *Plugin) user(username string) (user, bool, error) {
+	all := make(map[string]user)
+	err := p.loadData(dataUsers, &all)
+	if err != nil {
+		return user{}, false, err
+	}
+
+	u, ok := all[username]
+	return u, ok, nil
+}
+
+// saveUser creates or updates a stored user.
+func (p *Plugin) saveUser(u user) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]user)
+	err := p.loadData(dataUsers, &all)
+	if err != nil {
+		return err
+	}
+
+	all[u.Username] = u
+
+	return p.saveData(dataUsers, all)
+}
+
+// deleteUser removes a stored user. Their sessions end on the next request
+// since currentUser only accepts stored users and the site owner.
+func (p *Plugin) deleteUser(username string) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]user)
+	err := p.loadData(dataUsers, &all)
+	if err != nil {
+		return err
+	}
+
+	delete(all, username)
+
+	return p.saveData(dataUsers, all)
+}
+
+// ownerUsername returns the username of the site owner from the plugin
+// settings.
+func (p *Plugin) ownerUsername() (string, error) {
+	return p.Site.PluginSettingString(Username)
+}
+
+// authenticate returns nil if the username, password, and MFA token match
+// the site owner or a stored user. If only the MFA token is wrong,
+// errMFAFailed is returned so it can be audited separately.
+func (p *Plugin) authenticate(username string, password string, mfa string) error {
+	owner, err := p.ownerUsername()
+	if err != nil {
+		return err
+	}
+
+	var hash, mfakey string
+	if username == owner {
+		allowedPassword, err := p.Site.PluginSettingString(Password)
+		if err != nil {
+			return err
+		}
+
+		// Decode the hash - this is to allow it to be stored easily since
+		// dollar signs are difficult to work with.
+		hashDecoded, err := base64.StdEncoding.DecodeString(allowedPassword)
+		if err != nil {
+			return err
+		}
+		hash = string(hashDecoded)
+
+		mfakey, err = p.Site.PluginSettingString(MFAKey)
+		if err != nil {
+			return err
+		}
+	} else {
+		u, found, err := p.user(username)
+		if err != nil {
+			return err
+		} else if !found {
+			passhash.MatchString(unknownUserHash, password)
+			return errLoginFailed
+		}
+		hash = u.PasswordHash
+		mfakey = u.MFAKey
+	}
+
+	if !passhash.MatchString(hash, password) {
+		return errLoginFailed
+	}
+
+	// Let the MFA pass if it's not set up for the user.
+	if len(mfakey) > 0 {
+		if !validMFA(mfa, mfakey) {
+			return errMFAFailed
+		}
+	}
+
+	return nil
+}
+
+// currentUser returns the authenticated user and their role. The site owner
+// isn't stored and is an admin. Any other user must be stored so sessions of
+// deleted users are rejected with errUnknownUser.
+func (p *Plugin) currentUser(r *http.Request) (currentUser, error) {
+	username, err := p.Site.AuthenticatedUser(r)
+	if err != nil {
+		return currentUser{}, err
+	}
+
+	owner, err := p.ownerUsername()
+	if err != nil {
+		return currentUser{}, err
+	} else if username == owner {
+		return currentUser{Username: username, Role: roleAdmin}, nil
+	}
+
+	u, found, err := p.user(username)
+	if err != nil {
+		return currentUser{}, err
+	} else if !found {
+		return currentUser{}, errUnknownUser
+	}
+
+	// Sessions from before the user was created belong to a deleted user
+	// with the same username.
+	loginTime, err := strconv.ParseInt(p.Site.SessionValue(r, sessionLoginTime), 10, 64)
+	if err == nil && time.Unix(0, loginTime).Before(u.Created) {
+		return currentUser{}, errUnknownUser
+	}
+
+	return currentUser{Username: username, Role: u.Role}, nil
+}
+
+// endUnknownSession logs out the session if it belongs to a user that doesn't
+// exist anymore.
+func (p *Plugin) endUnknownSession(r *http.Request, err error) {
+	if err != errUnknownUser {
+		return
+	}
+
+	username, _ := p.Site.AuthenticatedUser(r)
+	p.Log.Info("bearblog: logging out session of unknown user: %v", username)
+	err = p.Site.UserLogout(r)
+	if err != nil {
+		p.Log.Info("bearblog: logout failed: %v", err.Error())
+	}
+}
+
+// requireRole returns a forbidden status error if the authenticated user
+// doesn't have one of the roles.
+func (p *Plugin) requireRole(r *http.Request, allowed ...string) error {
+	cu, err := p.currentUser(r)
+	if err != nil {
+		p.endUnknownSession(r, err)
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	for _, v := range allowed {
+		if cu.Role == v {
+			return nil
+		}
+	}
+
+	return p.Mux.StatusError(http.StatusForbidden, nil)
+}
+
+// canEditPost returns true if the user can edit the post with the ID.
+// Authors can only edit their own posts.
+func (p *Plugin) canEditPost(cu currentUser, ID string) (bool, error) {
+	if cu.Role != roleAuthor {
+		return true, nil
+	}
+
+	author, err := p.postAuthor(ID)
+	if err != nil {
+		return false, err
+	}
+
+	return author == cu.Username, nil
+}
+
+// authorizePost returns a forbidden status error if the authenticated user
+// can't edit the post with the ID.
+func (p *Plugin) authorizePost(r *http.Request, ID string) error {
+	cu, err := p.currentUser(r)
+	if err != nil {
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	allowed, err := p.canEditPost(cu, ID)
+	if err != nil {
+		return p.Site.Error(err)
+	} else if !allowed {
+		return p.Mux.StatusError(http.StatusForbidden, nil)
+	}
+
+	return nil
+}
+
+// postAuthors returns the username of the user that created each post mapped
+// to the post ID.
+func (p *Plugin) postAuthors() (map[string]string, error) {
+	all := make(map[string]string)
+	err := p.loadData(dataAuthors, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// postAuthor returns the username of the user that created the post. An
+// empty string is returned for posts created before authors were tracked.
+func (p *Plugin) postAuthor(ID string) (string, error) {
+	all, err := p.postAuthors()
+	if err != nil {
+		return "", err
+	}
+
+	return all[ID], nil
+}
+
+// setPostAuthor records the user that created a post. An empty username
+// removes the author.
+func (p *Plugin) setPostAuthor(ID string, username string) error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	all := make(map[string]string)
+	err := p.loadData(dataAuthors, &all)
+	if err != nil {
+		return err
+	}
+
+	if len(username) == 0 {
+		delete(all, ID)
+	} else {
+		all[ID] = username
+	}
+
+	return p.saveData(dataAuthors, all)
+}
+
+// authorName returns the name to show for the author of a post. The Author
+// setting is used for the site owner and for posts without an author.
+func (p *Plugin) authorName(ID string) (string, error) {
+	username, err := p.postAuthor(ID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(username) > 0 {
+		u, found, err := p.user(username)
+		if err != nil {
+			return "", err
+		} else if found {
+			if len(u.Name) > 0 {
+				return u.Name, nil
+			}
+			return u.Username, nil
+		}
+	}
+
+	return p.Site.PluginSettingString(Author)
+}
+
+// FeedAuthors returns the author names of published posts mapped to the post
+// slug so they can be added to the RSS feed plugin with AddAuthorSource.
+func (p *Plugin) FeedAuthors() (map[string]string, error) {
+	postsAndPages, err := p.livePostsAndPages()
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string)
+	for _, v := range postsAndPages {
+		name, err := p.authorName(v.ID)
+		if err != nil {
+			return nil, err
+		} else if len(name) > 0 {
+			m[v.URL] = name
+		}
+	}
+
+	return m, nil
+}
+
+// mfaEnabled returns true if the site owner or any user has MFA set up.
+func (p *Plugin) mfaEnabled() (bool, error) {
+	mfakey, err := p.Site.PluginSettingString(MFAKey)
+	if err != nil {
+		return false, err
+	} else if len(mfakey) > 0 {
+		return true, nil
+	}
+
+	arr, err := p.users()
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range arr {
+		if len(v.MFAKey) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// validMFA returns true if the token from an authenticator app matches the
+// MFA key.
+func validMFA(token string, mfakey string) bool {
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return false
+	}
+
+	ok, err := totp.Authenticate(i, mfakey)
+	return err == nil && ok
+}
@@ -0,0 +1,18 @@
+package bearblog
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUnknownUserHash(t *testing.T) {
+	// The hash has to cost as much to check as the hash of a real password.
+	cost, err := bcrypt.Cost([]byte(unknownUserHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Fatalf("expected cost %v, got %v", bcrypt.DefaultCost, cost)
+	}
+}
@@ -0,0 +1,192 @@
+package rssfeed
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+const (
+	// maxCachedFeeds is the most feeds kept in the cache. Each tag has its
+	// own feed so the cache is limited to keep memory from filling up.
+	maxCachedFeeds = 100
+	// cacheMaxAge is how long a cached feed is used. Categories and authors
+	// from other plugins can change without a post changing so the feed is
+	// built again once in a while to pick them up.
+	cacheMaxAge = 10 * time.Minute
+)
+
+// cachedFeed is a rendered feed. It's rebuilt when the posts or the settings
+// it uses change or when a scheduled post is published.
+type cachedFeed struct {
+	body []byte
+	etag string
+	// modified is the most recent time a post in the feed changed.
+	modified time.Time
+	// fingerprint is the hash of the posts and settings the feed was built
+	// from.
+	fingerprint string
+	// built is when the feed was built.
+	built time.Time
+	// expires is when the next scheduled post is published. It's zero if no
+	// posts are scheduled.
+	expires time.Time
+}
+
+// valid returns true if the feed can still be used.
+func (c cachedFeed) valid(fingerprint string, now time.Time) bool {
+	return c.fingerprint == fingerprint &&
+		now.Before(c.built.Add(cacheMaxAge)) &&
+		(c.expires.IsZero() || now.Before(c.expires))
+}
+
+// renderFeed returns the feed in a format from the cache or builds it if the
+// posts changed. The setting is the path of the feed. Only saving posts or
+// settings causes it to be built again so other saves of the site, like new
+// comments, don't.
+func (p *Plugin) renderFeed(setting string, tag string,
+	encode func(f feed, selfURL string) ([]byte, error)) (cachedFeed, error) {
+	postAndPages, err := p.Site.PostsAndPages(true)
+	if err != nil {
+		return cachedFeed{}, err
+	}
+
+	// The feed URL is first.
+	settings, err := p.feedSettings(setting)
+	if err != nil {
+		return cachedFeed{}, err
+	}
+	feedURL := settings[0]
+
+	// Tags match without case so they share a feed.
+	tag = strings.ToLower(tag)
+	fingerprint := feedFingerprint(append(settings, tag), postAndPages)
+
+	key := setting + "\n" + tag
+	now := time.Now()
+	p.cacheMu.Lock()
+	c, found := p.cache[key]
+	p.cacheMu.Unlock()
+	if found && c.valid(fingerprint, now) {
+		return c, nil
+	}
+
+	in, err := p.feedInput(tag, postAndPages)
+	if err != nil {
+		return cachedFeed{}, err
+	}
+
+	f := in.build()
+
+	selfURL := f.Link + feedURL
+	if len(tag) > 0 {
+		selfURL += "?tag=" + url.QueryEscape(tag)
+	}
+
+	body, err := encode(f, selfURL)
+	if err != nil {
+		return cachedFeed{}, err
+	}
+
+	c = cachedFeed{
+		body:        body,
+		etag:        fmt.Sprintf(`"%x"`, sha256.Sum256(body)),
+		modified:    f.Updated,
+		fingerprint: fingerprint,
+		built:       now,
+		expires:     f.NextPost,
+	}
+
+	// Tags without posts aren't cached so requests for made up tags can't
+	// fill up memory.
+	if len(tag) == 0 || len(f.Items) > 0 {
+		p.cacheFeed(key, c)
+	}
+
+	return c, nil
+}
+
+// cacheFeed adds a feed to the cache. Another feed is removed first if the
+// cache is full.
+func (p *Plugin) cacheFeed(key string, c cachedFeed) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+
+	if _, found := p.cache[key]; !found && len(p.cache) >= maxCachedFeeds {
+		for k := range p.cache {
+			delete(p.cache, k)
+			break
+		}
+	}
+
+	p.cache[key] = c
+}
+
+// feedSettings returns the value of the feed URL setting followed by the
+// site information and the other settings that change a feed.
+func (p *Plugin) feedSettings(setting string) ([]string, error) {
+	arr := make([]string, 0)
+	for _, name := range []string{setting, Description, Author, FullContent, MaxItems, ExcludePages} {
+		s, err := p.Site.PluginSettingString(name)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, s)
+	}
+
+	title, err := p.Site.Title()
+	if err != nil {
+		return nil, err
+	}
+
+	siteURL, err := p.Site.FullURL()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(arr, title, siteURL), nil
+}
+
+// feedFingerprint returns a hash of the settings and the posts that changes
+// when a post is saved. The content of posts isn't read since saving a post
+// also changes when it was updated.
+func feedFingerprint(settings []string, postAndPages ambient.PostWithIDList) string {
+	h := sha256.New()
+	for _, s := range settings {
+		fmt.Fprintf(h, "%q\n", s)
+	}
+
+	for _, v := range postAndPages {
+		fmt.Fprintf(h, "%q %q %q %v %v %v %q\n", v.ID, v.URL, v.Title,
+			v.Timestamp.UnixNano(), v.Updated.UnixNano(), v.Page, v.Tags.String())
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// notModified returns true if the client already has the feed. The
+// If-None-Match header is used before the If-Modified-Since header.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); len(inm) > 0 {
+		for _, v := range strings.Split(inm, ",") {
+			v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+			if v == etag || v == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates don't have fractions of a second.
+	return !modified.Truncate(time.Second).After(ims)
+}
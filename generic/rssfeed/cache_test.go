@@ -0,0 +1,119 @@
+package rssfeed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2021, 3, 1, 10, 30, 15, 500, time.UTC)
+	etag := `"abc"`
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected bool
+	}{
+		{"no headers", nil, false},
+		{"etag match", map[string]string{"If-None-Match": `"abc"`}, true},
+		{"etag list", map[string]string{"If-None-Match": `"xyz", W/"abc"`}, true},
+		{"etag star", map[string]string{"If-None-Match": `*`}, true},
+		{"etag mismatch", map[string]string{"If-None-Match": `"xyz"`}, false},
+		{"etag before date", map[string]string{
+			"If-None-Match":     `"xyz"`,
+			"If-Modified-Since": modified.Format(http.TimeFormat),
+		}, false},
+		{"same date", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, true},
+		{"later date", map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}, true},
+		{"earlier date", map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}, false},
+		{"bad date", map[string]string{"If-Modified-Since": "yesterday"}, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/rss.xml", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		if got := notModified(r, etag, modified); got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestCachedFeedValid(t *testing.T) {
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	c := cachedFeed{fingerprint: "a", built: now}
+	if !c.valid("a", now) {
+		t.Error("expected the feed to be valid when the posts didn't change")
+	}
+	if c.valid("b", now) {
+		t.Error("expected the feed to be invalid after the posts changed")
+	}
+	if c.valid("a", now.Add(cacheMaxAge)) {
+		t.Error("expected the feed to be invalid once it's too old")
+	}
+
+	c.expires = now.Add(time.Minute)
+	if !c.valid("a", now) {
+		t.Error("expected the feed to be valid before the scheduled post")
+	}
+	c.expires = now
+	if c.valid("a", now) {
+		t.Error("expected the feed to be invalid once the scheduled post is published")
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	post := ambient.Post{Tags: ambient.TagList{{Name: "Go"}, {Name: "web"}}}
+
+	if !hasTag(post, "") {
+		t.Error("expected every post to match an empty tag")
+	}
+	if !hasTag(post, "go") {
+		t.Error("expected tags to match without case")
+	}
+	if hasTag(post, "rust") {
+		t.Error("expected no match")
+	}
+}
+
+func TestFeedFingerprint(t *testing.T) {
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	settings := []string{"/rss.xml", "Site"}
+	posts := ambient.PostWithIDList{{ID: "1", Post: ambient.Post{Title: "Hello", Content: "a", Updated: now}}}
+
+	a := feedFingerprint(settings, posts)
+	if b := feedFingerprint(settings, posts); a != b {
+		t.Error("expected the same posts to have the same fingerprint")
+	}
+
+	posts[0].Updated = now.Add(time.Minute)
+	if b := feedFingerprint(settings, posts); b == a {
+		t.Error("expected the fingerprint to change when the post is saved")
+	}
+	posts[0].Updated = now
+
+	if b := feedFingerprint([]string{"/rss.xml", "Site", "go"}, posts); b == a {
+		t.Error("expected the fingerprint to change with the settings")
+	}
+}
+
+func TestCacheFeedLimit(t *testing.T) {
+	p := New()
+	for i := 0; i < maxCachedFeeds+10; i++ {
+		p.cacheFeed(fmt.Sprint(i), cachedFeed{})
+	}
+
+	if len(p.cache) != maxCachedFeeds {
+		t.Errorf("expected %v cached feeds, got %v", maxCachedFeeds, len(p.cache))
+	}
+	if _, found := p.cache[fmt.Sprint(maxCachedFeeds+9)]; !found {
+		t.Error("expected the newest feed to be cached")
+	}
+}
@@ -0,0 +1,306 @@
+package rssfeed
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+	"jaytaylor.com/html2text"
+)
+
+// feedLanguage is the language of every feed.
+const feedLanguage = "en-us"
+
+// defaultMaxItems is used when the max items setting is missing or invalid.
+const defaultMaxItems = 20
+
+// contentPolicy removes unsafe HTML from the full content of posts.
+var contentPolicy = bluemonday.UGCPolicy()
+
+// feed is the content of a feed that is shared by every format.
+type feed struct {
+	Title       string
+	Description string
+	// Link is the URL of the site.
+	Link     string
+	Language string
+	// Author is used for posts that don't have an author.
+	Author string
+	// Updated is the most recent time a post in the feed changed.
+	Updated time.Time
+	// NextPost is when the next scheduled post is published. It's zero if
+	// no posts are scheduled.
+	NextPost time.Time
+	Items    []feedItem
+}
+
+// feedItem is a post in a feed.
+type feedItem struct {
+	Title     string
+	Link      string
+	Published time.Time
+	Updated   time.Time
+	// Summary is the first sentence of the post as plaintext.
+	Summary string
+	// ContentHTML is the full post as sanitized HTML. It's empty if full
+	// content is turned off.
+	ContentHTML string
+	Author      string
+	Categories  []feedCategory
+}
+
+// feedCategory is a tag or a category from another plugin.
+type feedCategory struct {
+	Name string
+	// Domain is the URL of the page that lists the category. It's empty for
+	// tags.
+	Domain string
+}
+
+// feedInput is what a feed is built from.
+type feedInput struct {
+	// Feed has the site information without any items.
+	Feed feed
+	// Posts are the newest posts to show in the feed.
+	Posts ambient.PostWithIDList
+	// Full is true if the full content of posts is shown.
+	Full bool
+	// Categories from other plugins mapped to the post slug.
+	Categories map[string][]feedCategory
+	// Authors from other plugins mapped to the post slug.
+	Authors map[string]string
+}
+
+// feedInput returns the site information and the newest posts to show in a
+// feed. If the tag is set, only posts with the tag are included.
+func (p *Plugin) feedInput(tag string, postAndPages ambient.PostWithIDList) (feedInput, error) {
+	in := feedInput{
+		Feed: feed{
+			Language: feedLanguage,
+		},
+		Categories: make(map[string][]feedCategory),
+		Authors:    make(map[string]string),
+	}
+	f := &in.Feed
+
+	var err error
+	f.Title, err = p.Site.Title()
+	if err != nil {
+		return in, err
+	}
+
+	f.Link, err = p.Site.FullURL()
+	if err != nil {
+		return in, err
+	}
+
+	f.Description, err = p.Site.PluginSettingString(Description)
+	if err != nil {
+		return in, err
+	}
+
+	f.Author, err = p.Site.PluginSettingString(Author)
+	if err != nil {
+		return in, err
+	}
+
+	full, err := p.Site.PluginSettingString(FullContent)
+	if err != nil {
+		return in, err
+	}
+	in.Full = full == "true"
+
+	noPages, err := p.Site.PluginSettingString(ExcludePages)
+	if err != nil {
+		return in, err
+	}
+
+	// Categories from other plugins mapped to the post slug.
+	categories := make(map[string][]feedCategory)
+	for _, source := range p.sources {
+		arr, err := source()
+		if err != nil {
+			return in, err
+		}
+
+		for _, c := range arr {
+			for _, slug := range c.PostURLs {
+				categories[slug] = append(categories[slug], feedCategory{
+					Domain: f.Link + c.Path,
+					Name:   c.Name,
+				})
+			}
+		}
+	}
+
+	// Slugs of posts from other plugins to leave out of the feed.
+	excluded := make(map[string]bool)
+	for _, source := range p.excludes {
+		arr, err := source()
+		if err != nil {
+			return in, err
+		}
+
+		for _, slug := range arr {
+			excluded[slug] = true
+		}
+	}
+
+	// Authors from other plugins mapped to the post slug.
+	authors := make(map[string]string)
+	for _, source := range p.authors {
+		m, err := source()
+		if err != nil {
+			return in, err
+		}
+
+		for slug, name := range m {
+			authors[slug] = name
+		}
+	}
+
+	// The site title is the author of the feed if it isn't set so per-tag
+	// feeds don't use their own title.
+	if len(f.Author) == 0 {
+		f.Author = f.Title
+	}
+	if len(tag) > 0 {
+		f.Title += " - #" + tag
+	}
+
+	now := time.Now()
+	posts := make(ambient.PostWithIDList, 0, len(postAndPages))
+	for _, v := range postAndPages {
+		// Skip posts scheduled for a later date, but keep track of when the
+		// next one goes live.
+		if v.Timestamp.After(now) {
+			if f.NextPost.IsZero() || v.Timestamp.Before(f.NextPost) {
+				f.NextPost = v.Timestamp
+			}
+			continue
+		}
+
+		if excluded[v.URL] || (v.Page && noPages == "true") || !hasTag(v.Post, tag) {
+			continue
+		}
+
+		posts = append(posts, v)
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Timestamp.After(posts[j].Timestamp)
+	})
+	if max := p.maxItems(); len(posts) > max {
+		posts = posts[:max]
+	}
+	in.Posts = posts
+
+	// Only keep what the posts in the feed use.
+	for _, v := range posts {
+		if c, ok := categories[v.URL]; ok {
+			in.Categories[v.URL] = c
+		}
+		if name, ok := authors[v.URL]; ok {
+			in.Authors[v.URL] = name
+		}
+	}
+
+	return in, nil
+}
+
+// build returns the feed with an item for each post.
+func (in feedInput) build() feed {
+	f := in.Feed
+	for _, v := range in.Posts {
+		item := feedItem{
+			Title:     v.Title,
+			Link:      f.Link + "/" + v.URL,
+			Published: v.Timestamp,
+			Updated:   v.Updated,
+			Summary:   plaintextBlurb(v.Content),
+			Author:    in.Authors[v.URL],
+		}
+		if item.Updated.IsZero() {
+			item.Updated = item.Published
+		}
+		if in.Full {
+			item.ContentHTML = contentHTML(v.Content)
+		}
+
+		for _, t := range v.Tags {
+			item.Categories = append(item.Categories, feedCategory{Name: t.Name})
+		}
+		item.Categories = append(item.Categories, in.Categories[v.URL]...)
+
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+
+		f.Items = append(f.Items, item)
+	}
+
+	if f.Updated.IsZero() {
+		f.Updated = time.Now()
+	}
+
+	return f
+}
+
+// maxItems returns the most posts to show in a feed.
+func (p *Plugin) maxItems() int {
+	s, err := p.Site.PluginSettingString(MaxItems)
+	if err != nil {
+		p.Log.Warn("rssfeed: error getting max items: %v", err.Error())
+		return defaultMaxItems
+	}
+
+	max, err := strconv.Atoi(s)
+	if err != nil || max < 1 {
+		return defaultMaxItems
+	}
+
+	return max
+}
+
+// hasTag returns true if the post has the tag or if the tag is empty.
+func hasTag(post ambient.Post, tag string) bool {
+	if len(tag) == 0 {
+		return true
+	}
+
+	for _, t := range post.Tags {
+		if strings.EqualFold(t.Name, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contentHTML returns markdown content as HTML with unsafe elements removed.
+func contentHTML(s string) string {
+	// Ensure unix line endings are used when pulling out of JSON.
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	unsafeHTML := blackfriday.Run([]byte(s))
+
+	return string(contentPolicy.SanitizeBytes(unsafeHTML))
+}
+
+// plaintextBlurb returns a plaintext blurb from markdown content.
+func plaintextBlurb(s string) string {
+	unsafeHTML := blackfriday.Run([]byte(s))
+	plaintext, err := html2text.FromString(string(unsafeHTML))
+	if err != nil {
+		plaintext = s
+	}
+	period := strings.Index(plaintext, ". ")
+	if period > 0 {
+		plaintext = plaintext[:period+1]
+	}
+
+	return plaintext
+}
@@ -0,0 +1,150 @@
+package rssfeed
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() feed {
+	published := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	return feed{
+		Title:       "My Blog",
+		Description: "Thoughts",
+		Link:        "https://example.com",
+		Language:    feedLanguage,
+		Updated:     published.Add(time.Hour),
+		Items: []feedItem{
+			{
+				Title:       "Hello",
+				Link:        "https://example.com/hello",
+				Published:   published,
+				Updated:     published.Add(time.Hour),
+				Summary:     "Hi there.",
+				ContentHTML: "<p>Hi there. More <em>words</em>.</p>",
+				Author:      "Jo",
+				Categories: []feedCategory{
+					{Name: "go"},
+					{Name: "Series", Domain: "https://example.com/series/a"},
+				},
+			},
+			{
+				Title:     "Short",
+				Link:      "https://example.com/short",
+				Published: published,
+				Updated:   published,
+				Summary:   "Short.",
+			},
+		},
+	}
+}
+
+func TestEncodeRSS(t *testing.T) {
+	b, err := encodeRSS(testFeed(), "https://example.com/rss.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(b)
+	for _, expected := range []string{
+		`xmlns:content="http://purl.org/rss/1.0/modules/content/"`,
+		`<atom:link href="https://example.com/rss.xml" rel="self" type="application/rss+xml"></atom:link>`,
+		`<content:encoded>&lt;p&gt;Hi there. More &lt;em&gt;words&lt;/em&gt;.&lt;/p&gt;</content:encoded>`,
+		`<dc:creator>Jo</dc:creator>`,
+		`<dc:creator>My Blog</dc:creator>`,
+		`<category>go</category>`,
+		`<category domain="https://example.com/series/a">Series</category>`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("expected %v in:\n%v", expected, s)
+		}
+	}
+
+	if strings.Count(s, "<content:encoded>") != 1 {
+		t.Error("expected content only for the post with full content")
+	}
+}
+
+func TestEncodeAtom(t *testing.T) {
+	b, err := encodeAtom(testFeed(), "https://example.com/atom.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(b)
+	for _, expected := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="en-us">`,
+		`<link href="https://example.com/atom.xml" rel="self" type="application/atom+xml"></link>`,
+		`<updated>2021-03-01T01:00:00Z</updated>`,
+		`<published>2021-03-01T00:00:00Z</published>`,
+		`<author>`,
+		`<name>Jo</name>`,
+		`<name>My Blog</name>`,
+		`<content type="html">&lt;p&gt;Hi there.`,
+		`<category term="go"></category>`,
+		`<category term="Series" scheme="https://example.com/series/a"></category>`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("expected %v in:\n%v", expected, s)
+		}
+	}
+}
+
+func TestEncodeJSONFeed(t *testing.T) {
+	b, err := encodeJSONFeed(testFeed(), "https://example.com/feed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := struct {
+		Version string `json:"version"`
+		FeedURL string `json:"feed_url"`
+		Authors []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Items []struct {
+			ID           string   `json:"id"`
+			ContentHTML  string   `json:"content_html"`
+			ContentText  string   `json:"content_text"`
+			DateModified string   `json:"date_modified"`
+			Tags         []string `json:"tags"`
+		} `json:"items"`
+	}{}
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Version != "https://jsonfeed.org/version/1.1" || m.FeedURL != "https://example.com/feed.json" {
+		t.Errorf("unexpected feed: %v", string(b))
+	}
+	if len(m.Authors) != 1 || m.Authors[0].Name != "My Blog" {
+		t.Errorf("expected the site title as the author, got %v", m.Authors)
+	}
+	if len(m.Items) != 2 {
+		t.Fatalf("expected 2 items, got %v", len(m.Items))
+	}
+	if m.Items[0].ContentHTML == "" || m.Items[0].ContentText != "" {
+		t.Errorf("expected HTML content, got %+v", m.Items[0])
+	}
+	if m.Items[1].ContentHTML != "" || m.Items[1].ContentText != "Short." {
+		t.Errorf("expected the summary as text content, got %+v", m.Items[1])
+	}
+	if m.Items[0].DateModified != "2021-03-01T01:00:00Z" {
+		t.Errorf("unexpected modified date: %v", m.Items[0].DateModified)
+	}
+	if strings.Join(m.Items[0].Tags, ",") != "go,Series" {
+		t.Errorf("unexpected tags: %v", m.Items[0].Tags)
+	}
+}
+
+func TestContentHTML(t *testing.T) {
+	got := contentHTML("Hi **there**.\r\n\r\n<script>alert(1)</script>")
+	if !strings.Contains(got, "<strong>there</strong>") {
+		t.Errorf("expected markdown to be rendered, got %v", got)
+	}
+	if strings.Contains(got, "<script>") {
+		t.Errorf("expected scripts to be removed, got %v", got)
+	}
+}
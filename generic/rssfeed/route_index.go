@@ -1,28 +1,71 @@
 package rssfeed
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"net/http"
-	"path"
 	"strings"
 	"time"
-
-	"github.com/russross/blackfriday/v2"
-	"jaytaylor.com/html2text"
 )
 
 // Returns a page for web crawlers.
 func (p *Plugin) index(w http.ResponseWriter, r *http.Request) (err error) {
+	return p.serveFeed(w, r, FeedURL, "application/xml", encodeRSS)
+}
+
+// Returns the feed in the Atom format.
+func (p *Plugin) atom(w http.ResponseWriter, r *http.Request) (err error) {
+	return p.serveFeed(w, r, AtomURL, "application/atom+xml", encodeAtom)
+}
+
+// Returns the feed in the JSON Feed format.
+func (p *Plugin) jsonFeed(w http.ResponseWriter, r *http.Request) (err error) {
+	return p.serveFeed(w, r, JSONFeedURL, "application/feed+json", encodeJSONFeed)
+}
+
+// serveFeed writes the feed in a format. The setting is the path of the
+// feed. The tag query parameter limits the feed to posts with the tag.
+func (p *Plugin) serveFeed(w http.ResponseWriter, r *http.Request, setting string, contentType string,
+	encode func(f feed, selfURL string) ([]byte, error)) error {
+	tag := strings.TrimSpace(r.URL.Query().Get("tag"))
+
+	c, err := p.renderFeed(setting, tag, encode)
+	if err != nil {
+		return p.Site.Error(err)
+	}
+
+	w.Header().Set("ETag", c.etag)
+	w.Header().Set("Last-Modified", c.modified.UTC().Format(http.TimeFormat))
+	if notModified(r, c.etag, c.modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	fmt.Fprint(w, string(c.body))
+	return nil
+}
+
+// encodeRSS returns the feed in the RSS 2.0 format.
+func encodeRSS(f feed, selfURL string) ([]byte, error) {
 	// Resource: https://www.rssboard.org/rss-specification
 	// Rsource: https://validator.w3.org/feed/check.cgi
 
+	type ItemCategory struct {
+		Domain string `xml:"domain,attr,omitempty"`
+		Name   string `xml:",chardata"`
+	}
+
 	type Item struct {
-		Title       string `xml:"title"`
-		Link        string `xml:"link"`
-		PubDate     string `xml:"pubDate"`
-		GUID        string `xml:"guid"`
-		Description string `xml:"description"`
+		Title       string         `xml:"title"`
+		Link        string         `xml:"link"`
+		PubDate     string         `xml:"pubDate"`
+		GUID        string         `xml:"guid"`
+		Description string         `xml:"description"`
+		Content     string         `xml:"content:encoded,omitempty"`
+		Creator     string         `xml:"dc:creator,omitempty"`
+		Categories  []ItemCategory `xml:"category"`
 	}
 
 	type AtomLink struct {
@@ -35,6 +78,8 @@ func (p *Plugin) index(w http.ResponseWriter, r *http.Request) (err error) {
 		XMLName       xml.Name `xml:"rss"`
 		Version       string   `xml:"version,attr"`
 		Atom          string   `xml:"xmlns:atom,attr"`
+		Content       string   `xml:"xmlns:content,attr"`
+		DC            string   `xml:"xmlns:dc,attr"`
 		Title         string   `xml:"channel>title"`
 		Link          string   `xml:"channel>link"`
 		Description   string   `xml:"channel>description"`
@@ -45,82 +90,246 @@ func (p *Plugin) index(w http.ResponseWriter, r *http.Request) (err error) {
 		Items         []Item   `xml:"channel>item"`
 	}
 
-	title, err := p.Site.Title()
-	if err != nil {
-		return p.Site.Error(err)
-	}
-
-	siteURL, err := p.Site.FullURL()
-	if err != nil {
-		return p.Site.Error(err)
-	}
-
-	description, err := p.Site.PluginSettingString(Description)
-	if err != nil {
-		return p.Site.Error(err)
-	}
-
-	feedURL, err := p.Site.PluginSettingString(FeedURL)
-	if err != nil {
-		return p.Site.Error(err)
-	}
-
 	m := &Sitemap{
 		Version:       "2.0",
 		Atom:          "http://www.w3.org/2005/Atom",
-		Title:         title,
-		Link:          siteURL,
-		Description:   description,
+		Content:       "http://purl.org/rss/1.0/modules/content/",
+		DC:            "http://purl.org/dc/elements/1.1/",
+		Title:         f.Title,
+		Link:          f.Link,
+		Description:   f.Description,
 		Generator:     "Ambient",
-		Language:      "en-us",
-		LastBuildDate: time.Now().Format(time.RFC1123Z),
+		Language:      f.Language,
+		LastBuildDate: f.Updated.Format(time.RFC1123Z),
 		AtomLink: AtomLink{
-			Href: path.Join(siteURL, feedURL),
+			Href: selfURL,
 			Rel:  "self",
 			Type: "application/rss+xml",
 		},
 	}
 
-	postAndPages, err := p.Site.PostsAndPages(true)
+	for _, v := range f.Items {
+		item := Item{
+			Title:       v.Title,
+			Link:        v.Link,
+			PubDate:     v.Published.Format(time.RFC1123Z),
+			GUID:        v.Link,
+			Description: v.Summary,
+			Content:     v.ContentHTML,
+			Creator:     itemAuthor(f, v),
+		}
+
+		for _, c := range v.Categories {
+			item.Categories = append(item.Categories, ItemCategory{
+				Domain: c.Domain,
+				Name:   c.Name,
+			})
+		}
+
+		m.Items = append(m.Items, item)
+	}
+
+	output, err := xml.MarshalIndent(m, "  ", "    ")
 	if err != nil {
-		return p.Site.Error(err)
+		return nil, err
 	}
 
-	for _, v := range postAndPages {
-		plaintext := plaintextBlurb(v.Post.Content)
-		m.Items = append(m.Items, Item{
-			Title:       v.Title,
-			Link:        siteURL + "/" + v.URL,
-			PubDate:     v.Timestamp.Format(time.RFC1123Z),
-			GUID:        siteURL + "/" + v.URL,
-			Description: plaintext,
-		})
+	header := []byte(xml.Header)
+	return append(header[:], output[:]...), nil
+}
+
+// encodeAtom returns the feed in the Atom 1.0 format.
+func encodeAtom(f feed, selfURL string) ([]byte, error) {
+	// Resource: https://datatracker.ietf.org/doc/html/rfc4287
+
+	type Link struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr,omitempty"`
+		Type string `xml:"type,attr,omitempty"`
+	}
+
+	type Person struct {
+		Name string `xml:"name"`
+	}
+
+	type Text struct {
+		Type string `xml:"type,attr"`
+		Body string `xml:",chardata"`
+	}
+
+	type Category struct {
+		Term   string `xml:"term,attr"`
+		Scheme string `xml:"scheme,attr,omitempty"`
+	}
+
+	type Entry struct {
+		ID         string     `xml:"id"`
+		Title      string     `xml:"title"`
+		Link       Link       `xml:"link"`
+		Published  string     `xml:"published"`
+		Updated    string     `xml:"updated"`
+		Author     *Person    `xml:"author,omitempty"`
+		Summary    string     `xml:"summary,omitempty"`
+		Content    *Text      `xml:"content,omitempty"`
+		Categories []Category `xml:"category"`
+	}
+
+	type Feed struct {
+		XMLName   xml.Name `xml:"feed"`
+		Xmlns     string   `xml:"xmlns,attr"`
+		Lang      string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+		ID        string   `xml:"id"`
+		Title     string   `xml:"title"`
+		Subtitle  string   `xml:"subtitle,omitempty"`
+		Updated   string   `xml:"updated"`
+		Links     []Link   `xml:"link"`
+		Author    Person   `xml:"author"`
+		Generator string   `xml:"generator"`
+		Entries   []Entry  `xml:"entry"`
+	}
+
+	m := &Feed{
+		Xmlns:    "http://www.w3.org/2005/Atom",
+		Lang:     f.Language,
+		ID:       selfURL,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  f.Updated.Format(time.RFC3339),
+		Links: []Link{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+		// Atom requires an author for the feed or every entry.
+		Author:    Person{Name: feedAuthor(f)},
+		Generator: "Ambient",
+	}
+
+	for _, v := range f.Items {
+		entry := Entry{
+			ID:        v.Link,
+			Title:     v.Title,
+			Link:      Link{Href: v.Link, Rel: "alternate", Type: "text/html"},
+			Published: v.Published.Format(time.RFC3339),
+			Updated:   v.Updated.Format(time.RFC3339),
+			Summary:   v.Summary,
+		}
+
+		if len(v.Author) > 0 {
+			entry.Author = &Person{Name: v.Author}
+		}
+
+		if len(v.ContentHTML) > 0 {
+			entry.Content = &Text{Type: "html", Body: v.ContentHTML}
+		}
+
+		for _, c := range v.Categories {
+			entry.Categories = append(entry.Categories, Category{
+				Term:   c.Name,
+				Scheme: c.Domain,
+			})
+		}
+
+		m.Entries = append(m.Entries, entry)
 	}
 
 	output, err := xml.MarshalIndent(m, "  ", "    ")
 	if err != nil {
-		return p.Mux.StatusError(http.StatusInternalServerError, err)
+		return nil, err
 	}
 
 	header := []byte(xml.Header)
-	output = append(header[:], output[:]...)
+	return append(header[:], output[:]...), nil
+}
+
+// encodeJSONFeed returns the feed in the JSON Feed 1.1 format.
+func encodeJSONFeed(f feed, selfURL string) ([]byte, error) {
+	// Resource: https://www.jsonfeed.org/version/1.1/
+
+	type Author struct {
+		Name string `json:"name"`
+	}
+
+	type Item struct {
+		ID            string   `json:"id"`
+		URL           string   `json:"url"`
+		Title         string   `json:"title"`
+		ContentHTML   string   `json:"content_html,omitempty"`
+		ContentText   string   `json:"content_text,omitempty"`
+		Summary       string   `json:"summary,omitempty"`
+		DatePublished string   `json:"date_published"`
+		DateModified  string   `json:"date_modified"`
+		Authors       []Author `json:"authors,omitempty"`
+		Tags          []string `json:"tags,omitempty"`
+	}
+
+	type Feed struct {
+		Version     string   `json:"version"`
+		Title       string   `json:"title"`
+		HomePageURL string   `json:"home_page_url"`
+		FeedURL     string   `json:"feed_url"`
+		Description string   `json:"description,omitempty"`
+		Language    string   `json:"language"`
+		Authors     []Author `json:"authors"`
+		Items       []Item   `json:"items"`
+	}
+
+	m := &Feed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     selfURL,
+		Description: f.Description,
+		Language:    f.Language,
+		Authors:     []Author{{Name: feedAuthor(f)}},
+		Items:       make([]Item, 0, len(f.Items)),
+	}
+
+	for _, v := range f.Items {
+		item := Item{
+			ID:            v.Link,
+			URL:           v.Link,
+			Title:         v.Title,
+			ContentHTML:   v.ContentHTML,
+			Summary:       v.Summary,
+			DatePublished: v.Published.Format(time.RFC3339),
+			DateModified:  v.Updated.Format(time.RFC3339),
+		}
+
+		// Every item needs content so the summary is used if full content is
+		// turned off.
+		if len(item.ContentHTML) == 0 {
+			item.ContentText = v.Summary
+		}
 
-	w.Header().Set("Content-Type", "application/xml")
-	fmt.Fprint(w, string(output))
-	return
+		if len(v.Author) > 0 {
+			item.Authors = []Author{{Name: v.Author}}
+		}
+
+		for _, c := range v.Categories {
+			item.Tags = append(item.Tags, c.Name)
+		}
+
+		m.Items = append(m.Items, item)
+	}
+
+	return json.MarshalIndent(m, "", "  ")
 }
 
-// plaintextBlurb returns a plaintext blurb from markdown content.
-func plaintextBlurb(s string) string {
-	unsafeHTML := blackfriday.Run([]byte(s))
-	plaintext, err := html2text.FromString(string(unsafeHTML))
-	if err != nil {
-		plaintext = s
+// feedAuthor returns the author of the feed. The site title is used if the
+// author isn't set.
+func feedAuthor(f feed) string {
+	if len(f.Author) > 0 {
+		return f.Author
 	}
-	period := strings.Index(plaintext, ". ")
-	if period > 0 {
-		plaintext = plaintext[:period+1]
+
+	return f.Title
+}
+
+// itemAuthor returns the author of a post in the feed.
+func itemAuthor(f feed, item feedItem) string {
+	if len(item.Author) > 0 {
+		return item.Author
 	}
 
-	return plaintext
+	return feedAuthor(f)
 }
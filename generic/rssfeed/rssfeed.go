@@ -1,22 +1,76 @@
-// Package rssfeed is an Ambient plugin that provides an RSS feed.
+// Package rssfeed is an Ambient plugin that provides RSS, Atom, and JSON
+// feeds.
 package rssfeed
 
 import (
+	"strconv"
+	"sync"
+
 	"github.com/ambientkit/ambient"
 )
 
 // Plugin represents an Ambient plugin.
 type Plugin struct {
 	*ambient.PluginBase
+
+	sources  []CategorySource
+	excludes []ExcludeSource
+	authors  []AuthorSource
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedFeed
 }
 
-// New returns an Ambient plugin that provides an RSS feed.
+// Category is a group of posts from another plugin, like a series. Each post
+// in the category is labeled with it in the feed.
+type Category struct {
+	// Name is the name of the category.
+	Name string
+	// Path is the path of the page that lists the category without the site
+	// URL.
+	Path string
+	// PostURLs are the slugs of the posts in the category.
+	PostURLs []string
+}
+
+// CategorySource returns categories from another plugin to label posts in
+// the feed.
+type CategorySource func() ([]Category, error)
+
+// ExcludeSource returns the slugs of posts from another plugin to leave out
+// of the feed, like posts that are only shared by link.
+type ExcludeSource func() ([]string, error)
+
+// AuthorSource returns the author names of posts from another plugin mapped
+// to the post slug.
+type AuthorSource func() (map[string]string, error)
+
+// New returns an Ambient plugin that provides RSS, Atom, and JSON feeds.
 func New() *Plugin {
 	return &Plugin{
 		PluginBase: &ambient.PluginBase{},
+		cache:      make(map[string]cachedFeed),
 	}
 }
 
+// AddCategorySource adds a function that returns categories to label posts
+// in the feed.
+func (p *Plugin) AddCategorySource(source CategorySource) {
+	p.sources = append(p.sources, source)
+}
+
+// AddExcludeSource adds a function that returns the slugs of posts to leave
+// out of the feed.
+func (p *Plugin) AddExcludeSource(source ExcludeSource) {
+	p.excludes = append(p.excludes, source)
+}
+
+// AddAuthorSource adds a function that returns the authors of posts in the
+// feed. Posts without an author use the Author setting.
+func (p *Plugin) AddAuthorSource(source AuthorSource) {
+	p.authors = append(p.authors, source)
+}
+
 // PluginName returns the plugin name.
 func (p *Plugin) PluginName() string {
 	return "rssfeed"
@@ -42,8 +96,20 @@ func (p *Plugin) GrantRequests() []ambient.GrantRequest {
 const (
 	// FeedURL allows user to set the feed URL>
 	FeedURL = "Feed URL"
+	// AtomURL allows user to set the Atom feed URL.
+	AtomURL = "Atom URL"
+	// JSONFeedURL allows user to set the JSON feed URL.
+	JSONFeedURL = "JSON Feed URL"
 	// Description allows user to set the description.
 	Description = "Description"
+	// Author allows user to set the author of posts that don't have one.
+	Author = "Author"
+	// FullContent allows user to set if feeds include the full posts.
+	FullContent = "Full Content"
+	// MaxItems allows user to set the most posts in each feed.
+	MaxItems = "Max Items"
+	// ExcludePages allows user to set if pages are left out of the feeds.
+	ExcludePages = "Exclude Pages"
 )
 
 // Settings returns a list of user settable fields.
@@ -56,10 +122,51 @@ func (p *Plugin) Settings() []ambient.Setting {
 				Text: "Must start with a slash like this: /rss.xml",
 			},
 		},
+		{
+			Name:    AtomURL,
+			Default: "/atom.xml",
+			Description: ambient.SettingDescription{
+				Text: "Must start with a slash like this: /atom.xml",
+			},
+		},
+		{
+			Name:    JSONFeedURL,
+			Default: "/feed.json",
+			Description: ambient.SettingDescription{
+				Text: "Must start with a slash like this: /feed.json",
+			},
+		},
 		{
 			Name: Description,
 			Type: ambient.Textarea,
 		},
+		{
+			Name: Author,
+			Description: ambient.SettingDescription{
+				Text: "Author of posts that don't have their own. The site title is used if it's empty.",
+			},
+		},
+		{
+			Name: FullContent,
+			Type: ambient.Checkbox,
+			Description: ambient.SettingDescription{
+				Text: "Include the full post in feeds instead of only the first sentence.",
+			},
+		},
+		{
+			Name:    MaxItems,
+			Default: strconv.Itoa(defaultMaxItems),
+			Description: ambient.SettingDescription{
+				Text: "Most posts to show in each feed, starting with the newest.",
+			},
+		},
+		{
+			Name: ExcludePages,
+			Type: ambient.Checkbox,
+			Description: ambient.SettingDescription{
+				Text: "Leave pages out of the feeds so only posts are shown.",
+			},
+		},
 	}
 }
 
@@ -70,13 +177,25 @@ func (p *Plugin) Assets() ([]ambient.Asset, ambient.FileSystemReader) {
 		return nil, nil
 	}
 
-	feedURL, err := p.Site.PluginSettingString(FeedURL)
-	if err != nil {
-		return nil, nil
+	// Let feed readers find every format.
+	formats := []struct {
+		setting     string
+		contentType string
+		title       string
+	}{
+		{FeedURL, "application/rss+xml", siteTitle},
+		{AtomURL, "application/atom+xml", siteTitle + " (Atom)"},
+		{JSONFeedURL, "application/feed+json", siteTitle + " (JSON Feed)"},
 	}
 
-	return []ambient.Asset{
-		{
+	arr := make([]ambient.Asset, 0, len(formats))
+	for _, v := range formats {
+		feedURL, err := p.Site.PluginSettingString(v.setting)
+		if err != nil || len(feedURL) == 0 {
+			continue
+		}
+
+		arr = append(arr, ambient.Asset{
 			Filetype:   ambient.AssetGeneric,
 			Location:   ambient.LocationHead,
 			TagName:    "link",
@@ -84,32 +203,42 @@ func (p *Plugin) Assets() ([]ambient.Asset, ambient.FileSystemReader) {
 			Attributes: []ambient.Attribute{
 				{
 					Name:  "rel",
-					Value: "alternative",
+					Value: "alternate",
 				},
 				{
-					Name:  "href",
-					Value: feedURL,
+					Name:  "type",
+					Value: v.contentType,
 				},
 				{
-					Name:  "application",
-					Value: "rss+xml",
+					Name:  "href",
+					Value: feedURL,
 				},
 				{
 					Name:  "title",
-					Value: siteTitle,
+					Value: v.title,
 				},
 			},
-		},
-	}, nil
+		})
+	}
+
+	return arr, nil
 }
 
 // Routes sets routes for the plugin.
 func (p *Plugin) Routes() {
-	// FIXME: This can't be changed dynamically.
+	// FIXME: These can't be changed dynamically.
 	feedURL, err := p.Site.PluginSettingString(FeedURL)
-	if err != nil {
-		return
+	if err == nil && len(feedURL) > 0 {
+		p.Mux.Get(feedURL, p.index)
 	}
 
-	p.Mux.Get(feedURL, p.index)
+	atomURL, err := p.Site.PluginSettingString(AtomURL)
+	if err == nil && len(atomURL) > 0 {
+		p.Mux.Get(atomURL, p.atom)
+	}
+
+	jsonFeedURL, err := p.Site.PluginSettingString(JSONFeedURL)
+	if err == nil && len(jsonFeedURL) > 0 {
+		p.Mux.Get(jsonFeedURL, p.jsonFeed)
+	}
 }
@@ -4,6 +4,12 @@ import (
 	"encoding/xml"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ambientkit/ambient"
 )
 
 // Returns a page for web crawlers.
@@ -11,9 +17,17 @@ func (p *Plugin) index(w http.ResponseWriter, r *http.Request) (err error) {
 	// Resource: https://www.sitemaps.org/protocol.html
 	// Resource: https://golang.org/src/encoding/xml/example_test.go
 
+	// Resource: https://developers.google.com/search/docs/specialty/international/localized-versions#sitemap
+	type Link struct {
+		Rel      string `xml:"rel,attr"`
+		Hreflang string `xml:"hreflang,attr"`
+		Href     string `xml:"href,attr"`
+	}
+
 	type URL struct {
 		Location     string `xml:"loc"`
 		LastModified string `xml:"lastmod"`
+		Alternates   []Link `xml:"xhtml:link"`
 	}
 
 	type Sitemap struct {
@@ -49,25 +63,83 @@ func (p *Plugin) index(w http.ResponseWriter, r *http.Request) (err error) {
 	if err != nil {
 		return p.Site.Error(err)
 	}
+	// Slugs of posts from other plugins to leave out of the sitemap.
+	excluded := make(map[string]bool)
+	for _, source := range p.excludes {
+		arr, err := source()
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		for _, slug := range arr {
+			excluded[slug] = true
+		}
+	}
+
+	now := time.Now()
+	tags := make(map[string]ambient.Tag)
 	for _, v := range postsAndPages {
+		// Skip posts scheduled for a later date.
+		if v.Timestamp.After(now) || excluded[v.URL] {
+			continue
+		}
+
 		m.URL = append(m.URL, URL{
 			Location:     siteURL + "/" + v.URL,
 			LastModified: v.Timestamp.Format("2006-01-02"),
 		})
+
+		for _, t := range v.Tags {
+			tags[t.Name] = t
+		}
 	}
 
 	// Tags
-	tags, err := p.Site.Tags(true)
-	if err != nil {
-		return p.Site.Error(err)
-	}
+	tagList := make(ambient.TagList, 0, len(tags))
 	for _, v := range tags {
+		tagList = append(tagList, v)
+	}
+	sort.Sort(tagList)
+	for _, v := range tagList {
 		m.URL = append(m.URL, URL{
-			Location:     siteURL + "/blog?q=" + v.Name,
+			Location:     siteURL + "/blog/tag/" + url.PathEscape(v.Name),
 			LastModified: v.Timestamp.Format("2006-01-02"),
 		})
 	}
 
+	// Pages from other plugins
+	for _, source := range p.sources {
+		pages, err := source()
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		for _, v := range pages {
+			m.URL = append(m.URL, URL{
+				Location:     siteURL + v.Path,
+				LastModified: v.LastModified.Format("2006-01-02"),
+			})
+		}
+	}
+
+	// Language versions of pages from other plugins
+	for _, source := range p.alternates {
+		alternates, err := source()
+		if err != nil {
+			return p.Site.Error(err)
+		}
+
+		for i, v := range m.URL {
+			for _, a := range alternates[strings.TrimPrefix(v.Location, siteURL)] {
+				m.URL[i].Alternates = append(m.URL[i].Alternates, Link{
+					Rel:      "alternate",
+					Hreflang: a.Lang,
+					Href:     siteURL + a.Path,
+				})
+			}
+		}
+	}
+
 	output, err := xml.MarshalIndent(m, "  ", "    ")
 	if err != nil {
 		return p.Mux.StatusError(http.StatusInternalServerError, err)
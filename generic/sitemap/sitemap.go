@@ -1,13 +1,50 @@
 // Package sitemap is an Ambient plugin that provides a sitemap.
 package sitemap
 
-import "github.com/ambientkit/ambient"
+import (
+	"time"
+
+	"github.com/ambientkit/ambient"
+)
 
 // Plugin represents an Ambient plugin.
 type Plugin struct {
 	*ambient.PluginBase
+
+	sources    []PageSource
+	excludes   []ExcludeSource
+	alternates []AlternateSource
 }
 
+// Page is a page from another plugin to include in the sitemap.
+type Page struct {
+	// Path is the path of the page without the site URL.
+	Path string
+	// LastModified is when the content of the page last changed.
+	LastModified time.Time
+}
+
+// PageSource returns pages from another plugin to include in the sitemap,
+// like archive pages that aren't posts.
+type PageSource func() ([]Page, error)
+
+// ExcludeSource returns the slugs of posts from another plugin to leave out
+// of the sitemap, like posts that are only shared by link.
+type ExcludeSource func() ([]string, error)
+
+// Alternate is a version of a page in another language.
+type Alternate struct {
+	// Lang is the language code of the version, like "de" or "en-GB".
+	Lang string
+	// Path is the path of the version without the site URL.
+	Path string
+}
+
+// AlternateSource returns the language versions of pages from another plugin
+// mapped to the path of each page. The versions of a page should include the
+// page itself.
+type AlternateSource func() (map[string][]Alternate, error)
+
 // New returns an Ambient plugin that provides a sitemap.
 func New() *Plugin {
 	return &Plugin{
@@ -15,6 +52,23 @@ func New() *Plugin {
 	}
 }
 
+// AddPageSource adds a function that returns pages to include in the sitemap.
+func (p *Plugin) AddPageSource(source PageSource) {
+	p.sources = append(p.sources, source)
+}
+
+// AddExcludeSource adds a function that returns the slugs of posts to leave
+// out of the sitemap.
+func (p *Plugin) AddExcludeSource(source ExcludeSource) {
+	p.excludes = append(p.excludes, source)
+}
+
+// AddAlternateSource adds a function that returns the language versions of
+// pages to link in the sitemap.
+func (p *Plugin) AddAlternateSource(source AlternateSource) {
+	p.alternates = append(p.alternates, source)
+}
+
 // PluginName returns the plugin name.
 func (p *Plugin) PluginName() string {
 	return "sitemap"
@@ -32,9 +86,37 @@ func (p *Plugin) GrantRequests() []ambient.GrantRequest {
 		{Grant: ambient.GrantSiteSchemeRead, Description: "Access to read the site scheme."},
 		{Grant: ambient.GrantSiteUpdatedRead, Description: "Access to read the last updated date."},
 		{Grant: ambient.GrantSitePostRead, Description: "Access to read all the posts."},
+		{Grant: ambient.GrantSiteAssetWrite, Description: "Access to link the sitemap in the header."},
 	}
 }
 
+// Assets returns a list of assets and an embedded filesystem.
+func (p *Plugin) Assets() ([]ambient.Asset, ambient.FileSystemReader) {
+	// Let crawlers and static exports find the sitemap.
+	return []ambient.Asset{
+		{
+			Filetype:   ambient.AssetGeneric,
+			Location:   ambient.LocationHead,
+			TagName:    "link",
+			ClosingTag: false,
+			Attributes: []ambient.Attribute{
+				{
+					Name:  "rel",
+					Value: "sitemap",
+				},
+				{
+					Name:  "type",
+					Value: "application/xml",
+				},
+				{
+					Name:  "href",
+					Value: "/sitemap.xml",
+				},
+			},
+		},
+	}, nil
+}
+
 // Routes sets routes for the plugin.
 func (p *Plugin) Routes() {
 	p.Mux.Get("/sitemap.xml", p.index)
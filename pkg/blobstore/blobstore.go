@@ -0,0 +1,97 @@
+// Package blobstore provides read and write access to files stored by name.
+package blobstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidName is returned when a name would be stored outside of the store.
+var ErrInvalidName = errors.New("blobstore: invalid name")
+
+// Store saves and loads files by name. Load should return an error that
+// wraps os.ErrNotExist when the file doesn't exist.
+type Store interface {
+	Save(name string, b []byte) error
+	Load(name string) ([]byte, error)
+	Delete(name string) error
+}
+
+// LocalStore represents files in a folder on the local filesystem.
+type LocalStore struct {
+	dir string
+	m   *sync.RWMutex
+}
+
+// NewLocalStore returns a local filesystem store that saves files in a folder.
+// The folder is created when the first file is saved.
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{
+		dir: dir,
+		m:   &sync.RWMutex{},
+	}
+}
+
+// Save writes a file to the folder and returns an error if one occurs.
+func (s *LocalStore) Save(name string, b []byte) error {
+	fullPath, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	err = os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fullPath, b, 0644)
+}
+
+// Load returns a file contents from the folder.
+func (s *LocalStore) Load(name string) ([]byte, error) {
+	fullPath, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return ioutil.ReadFile(fullPath)
+}
+
+// Delete removes a file from the folder. It's not an error if the file
+// doesn't exist.
+func (s *LocalStore) Delete(name string) error {
+	fullPath, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	err = os.Remove(fullPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// path returns the location of a file in the folder. Names that contain path
+// separators are rejected so files can't be read or written outside of it.
+func (s *LocalStore) path(name string) (string, error) {
+	if len(name) == 0 || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", ErrInvalidName
+	}
+
+	return filepath.Join(s.dir, name), nil
+}
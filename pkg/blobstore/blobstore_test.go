@@ -0,0 +1,39 @@
+package blobstore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ambientkit/plugin/pkg/blobstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	s := blobstore.NewLocalStore(dir)
+
+	_, err := s.Load("a.png")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	assert.NoError(t, s.Save("a.png", []byte("data")))
+
+	b, err := s.Load("a.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(b))
+
+	assert.NoError(t, s.Delete("a.png"))
+	assert.NoError(t, s.Delete("a.png"))
+
+	_, err = s.Load("a.png")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestLocalStoreInvalidName(t *testing.T) {
+	s := blobstore.NewLocalStore(t.TempDir())
+
+	for _, name := range []string{"", "..", "../a.png", "a/b.png", `a\b.png`} {
+		assert.Equal(t, blobstore.ErrInvalidName, s.Save(name, []byte("data")), name)
+	}
+}